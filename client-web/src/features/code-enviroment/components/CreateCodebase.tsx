@@ -147,7 +147,9 @@ const CreateCodebase: React.FC<CreateCodebaseProps> = ({ projectToken, userSessi
         if (onSubmit) {
             onSubmit(formData)
         } else {
-            const resp = await axios.post(`${process.env.NEXT_PUBLIC_PROJECTS_SERVER}/api/repositories/create`, formData)
+            const resp = await axios.post(`${process.env.NEXT_PUBLIC_PROJECTS_SERVER}/api/repositories/create`, formData, {
+                headers: { Authorization: `Bearer ${formData.userSessionToken}` }
+            })
             console.log(resp.data)
             // refreshPage
             window.location.reload()
@@ -21,7 +21,8 @@ const LeftPanel: React.FC<ILeftPanel> = props => {
         dispatch(setIsLoading(true))
         try {
             const response = await axios.get(`${process.env.NEXT_PUBLIC_PROJECTS_SERVER}/api/projects/repo-tree`, {
-                params: { projectToken: props.ProjectToken }
+                params: { projectToken: props.ProjectToken },
+                headers: { Authorization: `Bearer ${session.data?.accessToken}` }
             })
             dispatch(setFileTree(response.data))
         } catch (error) {
@@ -69,6 +69,8 @@ export const fileSlice = createSlice({
                                 projectToken: projectToken,
                                 userSessionToken: userSessionToken,
                                 path: stripReposPath(nodes[i].path)
+                            }, {
+                                headers: { Authorization: `Bearer ${userSessionToken}` }
                             })
                             console.log(resp)
                             if (resp.status === 200) {
@@ -142,6 +144,8 @@ export const fileSlice = createSlice({
                                 projectToken: projectToken,
                                 userSessionToken: userSessionToken,
                                 path: stripReposPath(nodes[i].path)
+                            }, {
+                                headers: { Authorization: `Bearer ${userSessionToken}` }
                             })
 
                             if (resp.status === 200) {
@@ -26,7 +26,8 @@ const FileEditor = ({ filePath, projectToken, userSessionToken, repoUrl }: { fil
 
             try {
                 const response = await axios.get(`${process.env.NEXT_PUBLIC_PROJECTS_SERVER}/api/projects/repo-file`, {
-                    params: { path: filePath, projectToken: projectToken, userSessionToken: userSessionToken }
+                    params: { path: filePath, projectToken: projectToken, userSessionToken: userSessionToken },
+                    headers: { Authorization: `Bearer ${userSessionToken}` }
                 })
 
                 if (getLanguageFromFilePath(filePath) === 'json') {
@@ -59,6 +60,8 @@ const FileEditor = ({ filePath, projectToken, userSessionToken, repoUrl }: { fil
                 projectToken: projectToken,
                 userSessionToken: userSessionToken,
                 content: fileContent
+            }, {
+                headers: { Authorization: `Bearer ${userSessionToken}` }
             })
             setFileSaved(true)
         } catch (error) {
@@ -99,6 +102,8 @@ const FileEditor = ({ filePath, projectToken, userSessionToken, repoUrl }: { fil
             const response = await axios.post(`${process.env.NEXT_PUBLIC_PROJECTS_SERVER}/api/repositories/generate-repository`, {
                 projectToken: projectToken,
                 userSessionToken: userSessionToken
+            }, {
+                headers: { Authorization: `Bearer ${userSessionToken}` }
             })
 
             if (response.status === 200) {
@@ -202,7 +202,8 @@ const RightPanel: React.FC<IRightPanel> = ({ main_socket, deployments_socket, us
                     path: 'project-config.json',
                     projectToken,
                     userSessionToken
-                }
+                },
+                headers: { Authorization: `Bearer ${userSessionToken}` }
             })
 
             setProjectConfig(response.data)
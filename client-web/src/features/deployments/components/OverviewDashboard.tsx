@@ -60,7 +60,9 @@ const DeploymentsOverView: React.FC<{ projectToken: string; userSessionToken: st
 
     useEffect(() => {
         ;(async () => {
-            const resp = await axios.get(`${process.env.NEXT_PUBLIC_PROJECTS_SERVER}/api/projects/repo-file?projectToken=${projectToken}&path=project-config.json&branch=main`)
+            const resp = await axios.get(`${process.env.NEXT_PUBLIC_PROJECTS_SERVER}/api/projects/repo-file?projectToken=${projectToken}&path=project-config.json&branch=main`, {
+                headers: { Authorization: `Bearer ${userSessionToken}` }
+            })
             if (resp.data.error) {
                 console.error('Error fetching requests:', resp.data.error)
                 return
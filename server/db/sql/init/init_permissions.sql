@@ -52,7 +52,8 @@ FROM (
 
     -- Users
     SELECT 'USER_MANAGE', 'Manage user accounts', 'user', 'manage' UNION
-    SELECT 'ROLE_MANAGE', 'Manage user roles', 'role', 'manage'
+    SELECT 'ROLE_MANAGE', 'Manage user roles', 'role', 'manage' UNION
+    SELECT 'ROLE_ASSIGN', 'Invite members and assign their roles', 'role', 'assign'
 ) p
 JOIN resources r ON r.name = p.res
 JOIN actions a ON a.name = p.act;
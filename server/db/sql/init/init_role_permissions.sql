@@ -19,6 +19,7 @@ WHERE
             'PROJECT_CREATE',
             'BUDGET_MANAGE',
             'USER_MANAGE',
+            'ROLE_ASSIGN',
             'DOCUMENTATION_CREATE',
             'DOCUMENTATION_READ',
             'TASK_CREATE',
@@ -0,0 +1,15 @@
+CREATE TABLE personal_access_tokens (
+    id SERIAL PRIMARY KEY,
+    userprivatetoken VARCHAR(250) NOT NULL REFERENCES users(UserPrivateToken) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the token, the token itself is never stored
+    token_prefix VARCHAR(16) NOT NULL, -- Start of the token so users can recognize it
+    scopes TEXT[] NOT NULL, -- 'code:read', 'code:write', 'project:read', ...
+    projecttoken VARCHAR(250), -- NULL when the token works for every project of the user
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_personal_access_tokens_user ON personal_access_tokens(userprivatetoken);
@@ -0,0 +1,26 @@
+CREATE TABLE project_releases (
+    id SERIAL PRIMARY KEY,
+    projecttoken VARCHAR(250) NOT NULL,
+    tag_name VARCHAR(250) NOT NULL, -- Tag of the project's repository the release ships
+    name VARCHAR(200) NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published')),
+    created_by VARCHAR(250) NOT NULL REFERENCES users(UserPrivateToken),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP,
+    UNIQUE (projecttoken, tag_name)
+);
+
+CREATE TABLE project_release_artifacts (
+    id SERIAL PRIMARY KEY,
+    release_id INTEGER NOT NULL REFERENCES project_releases(id) ON DELETE CASCADE,
+    file_name VARCHAR(250) NOT NULL,
+    storage_path TEXT NOT NULL, -- Relative to RELEASES_FOLDER_PATH, which the file server must not serve
+    size_bytes BIGINT NOT NULL,
+    content_type VARCHAR(250) NOT NULL,
+    uploaded_by VARCHAR(250) NOT NULL REFERENCES users(UserPrivateToken),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_project_release_artifacts_release ON project_release_artifacts(release_id);
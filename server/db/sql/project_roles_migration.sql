@@ -0,0 +1,8 @@
+-- Adds project-scoped roles to databases created before roles.project_token
+-- existed
+
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS project_token VARCHAR(250);
+
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS roles_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_global_name ON roles(name) WHERE project_token IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_project_name ON roles(project_token, name) WHERE project_token IS NOT NULL;
@@ -0,0 +1,65 @@
+-- Notifies the project-manager servers when permission check results may
+-- change so they can drop their cached results. The payload is the project
+-- token for membership and ownership changes, empty for role changes
+-- affecting everyone.
+
+CREATE OR REPLACE FUNCTION notify_team_member_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM pg_notify('rbac_changed', OLD.projecttoken);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM pg_notify('rbac_changed', NEW.projecttoken);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_role_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('rbac_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The owner passes every permission check, so a transfer changes results
+CREATE OR REPLACE FUNCTION notify_project_owner_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('rbac_changed', OLD.projecttoken);
+    ELSIF OLD.projectownertoken IS DISTINCT FROM NEW.projectownertoken THEN
+        PERFORM pg_notify('rbac_changed', OLD.projecttoken);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS projects_team_members_rbac_notify ON projects_team_members;
+CREATE TRIGGER projects_team_members_rbac_notify
+AFTER INSERT OR UPDATE OR DELETE ON projects_team_members
+FOR EACH ROW EXECUTE FUNCTION notify_team_member_change();
+
+DROP TRIGGER IF EXISTS projects_team_members_rbac_notify_truncate ON projects_team_members;
+CREATE TRIGGER projects_team_members_rbac_notify_truncate
+AFTER TRUNCATE ON projects_team_members
+FOR EACH STATEMENT EXECUTE FUNCTION notify_role_change();
+
+DROP TRIGGER IF EXISTS role_permissions_rbac_notify ON role_permissions;
+CREATE TRIGGER role_permissions_rbac_notify
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON role_permissions
+FOR EACH STATEMENT EXECUTE FUNCTION notify_role_change();
+
+DROP TRIGGER IF EXISTS role_inheritance_rbac_notify ON role_inheritance;
+CREATE TRIGGER role_inheritance_rbac_notify
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON role_inheritance
+FOR EACH STATEMENT EXECUTE FUNCTION notify_role_change();
+
+DROP TRIGGER IF EXISTS roles_rbac_notify ON roles;
+CREATE TRIGGER roles_rbac_notify
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON roles
+FOR EACH STATEMENT EXECUTE FUNCTION notify_role_change();
+
+DROP TRIGGER IF EXISTS projects_owner_rbac_notify ON projects;
+CREATE TRIGGER projects_owner_rbac_notify
+AFTER UPDATE OF projectownertoken OR DELETE ON projects
+FOR EACH ROW EXECUTE FUNCTION notify_project_owner_change();
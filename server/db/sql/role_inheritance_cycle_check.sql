@@ -0,0 +1,33 @@
+-- Rejects role_inheritance rows that would make a role inherit from itself,
+-- directly or through other roles
+
+CREATE OR REPLACE FUNCTION check_role_inheritance_cycle() RETURNS trigger AS $$
+BEGIN
+    -- Serializes writers so two concurrent inserts cannot close a cycle together
+    PERFORM pg_advisory_xact_lock(hashtext('role_inheritance'));
+
+    IF EXISTS (
+        WITH RECURSIVE ancestors (role_id) AS (
+            SELECT parent_role_id FROM role_inheritance
+            WHERE child_role_id = NEW.parent_role_id
+            AND id IS DISTINCT FROM NEW.id
+            UNION
+            SELECT ri.parent_role_id FROM role_inheritance ri
+            JOIN ancestors a ON ri.child_role_id = a.role_id
+            WHERE ri.id IS DISTINCT FROM NEW.id
+        )
+        SELECT 1 FROM ancestors WHERE role_id = NEW.child_role_id
+    ) OR NEW.parent_role_id = NEW.child_role_id THEN
+        RAISE EXCEPTION 'role inheritance cycle: role % already inherits from role %',
+            NEW.parent_role_id, NEW.child_role_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS role_inheritance_cycle_check ON role_inheritance;
+CREATE TRIGGER role_inheritance_cycle_check
+BEFORE INSERT OR UPDATE OF parent_role_id, child_role_id ON role_inheritance
+FOR EACH ROW EXECUTE FUNCTION check_role_inheritance_cycle();
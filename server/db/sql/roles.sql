@@ -1,12 +1,17 @@
 CREATE TABLE roles (
     id SERIAL PRIMARY KEY,
-    name VARCHAR(100) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
     display_name VARCHAR(100) NOT NULL,
     description TEXT,
     category_id INTEGER REFERENCES role_categories(id),
     level INTEGER NOT NULL, -- Higher level = more permissions
     is_system_role BOOLEAN DEFAULT false,
     is_active BOOLEAN DEFAULT true,
+    project_token VARCHAR(250), -- NULL for global roles, set for roles defined by one project
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
+);
+
+-- Global role names are unique, project roles only within their project
+CREATE UNIQUE INDEX idx_roles_global_name ON roles(name) WHERE project_token IS NULL;
+CREATE UNIQUE INDEX idx_roles_project_name ON roles(project_token, name) WHERE project_token IS NOT NULL;
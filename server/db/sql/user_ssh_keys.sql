@@ -0,0 +1,11 @@
+CREATE TABLE user_ssh_keys (
+    id SERIAL PRIMARY KEY,
+    userprivatetoken VARCHAR(250) NOT NULL REFERENCES users(UserPrivateToken) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    public_key TEXT NOT NULL, -- authorized_keys format
+    fingerprint VARCHAR(100) NOT NULL UNIQUE, -- SHA256:... as printed by ssh-keygen -l
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_ssh_keys_user ON user_ssh_keys(userprivatetoken);
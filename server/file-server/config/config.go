@@ -9,7 +9,9 @@ import (
 
 )
 
-func InitDB() (*sql.DB, error) {
+// OpenDB prepares the connection pool without contacting the database, so
+// callers can start up while the database is still unavailable.
+func OpenDB() (*sql.DB, error) {
 
 	// Access the environment variables
 	dbHost := os.Getenv("POSTGRESQL_HOST")
@@ -29,7 +31,11 @@ func InitDB() (*sql.DB, error) {
 	)
 
 	// Open a database connection
-	db, err := sql.Open("postgres", dataSourceName)
+	return sql.Open("postgres", dataSourceName)
+}
+
+func InitDB() (*sql.DB, error) {
+	db, err := OpenDB()
 	if err != nil {
 		return nil, err
 	}
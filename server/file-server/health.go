@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// HealthChecker answers the liveness and readiness probes.
+type HealthChecker struct {
+	db           *sql.DB // nil when no database is configured
+	readableDirs map[string]string
+	writableDirs map[string]string
+	draining     atomic.Bool
+}
+
+func NewHealthChecker(db *sql.DB, readableDirs, writableDirs map[string]string) *HealthChecker {
+	return &HealthChecker{
+		db:           db,
+		readableDirs: readableDirs,
+		writableDirs: writableDirs,
+	}
+}
+
+// SetDraining makes readiness fail so no new traffic is routed to the
+// server while it shuts down.
+func (h *HealthChecker) SetDraining() {
+	h.draining.Store(true)
+}
+
+func (h *HealthChecker) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+func (h *HealthChecker) Ready(c *fiber.Ctx) error {
+	if h.draining.Load() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "draining"})
+	}
+
+	checks := fiber.Map{}
+	ready := true
+
+	if h.db != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+		defer cancel()
+
+		if err := h.db.PingContext(ctx); err != nil {
+			checks["database"] = failedCheck("database", err)
+			ready = false
+		} else {
+			checks["database"] = "ok"
+		}
+	}
+
+	for name, dir := range h.readableDirs {
+		if err := checkReadable(dir); err != nil {
+			checks[name] = failedCheck(name, err)
+			ready = false
+		} else {
+			checks[name] = "ok"
+		}
+	}
+
+	for name, dir := range h.writableDirs {
+		if err := checkWritable(dir); err != nil {
+			checks[name] = failedCheck(name, err)
+			ready = false
+		} else {
+			checks[name] = "ok"
+		}
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		checks["git"] = failedCheck("git", err)
+		ready = false
+	} else {
+		checks["git"] = "ok"
+	}
+
+	if !ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "not ready", "checks": checks})
+	}
+	return c.JSON(fiber.Map{"status": "ready", "checks": checks})
+}
+
+func checkReadable(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Readdirnames(1)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func checkWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".ready-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// failedCheck logs why a readiness check failed. Probes are unauthenticated,
+// errors name paths and connection details so they stay in the log.
+func failedCheck(name string, err error) string {
+	log.Printf("Readiness check %s failed: %v", name, err)
+	return "fail"
+}
@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"database/sql"
+	appconfig "file-server/config"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Config struct {
@@ -24,11 +32,18 @@ type Config struct {
 	ProjectsDir         string
 	ReposDir            string
 	ProjectsFinancesDir string
+	SitesDir            string
+	PagesBranch         string
+	ShutdownTimeout     time.Duration
+	ProxyHeader         string
+	RateLimitRedisURL   string
+	BandwidthLimit      int
 }
 
 type Cache struct {
 	mu    sync.RWMutex
 	files map[string]cachedFile
+	size  int64
 }
 
 type cachedFile struct {
@@ -46,22 +61,54 @@ func (c *Cache) Get(path string) ([]byte, time.Time, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	cached, exists := c.files[path]
+	if exists {
+		cacheHits.Inc()
+	} else {
+		cacheMisses.Inc()
+	}
 	return cached.content, cached.timestamp, exists
 }
 
 func (c *Cache) Set(path string, content []byte) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if previous, exists := c.files[path]; exists {
+		c.size -= int64(len(previous.content))
+	}
 	c.files[path] = cachedFile{
 		content:   content,
 		timestamp: time.Now(),
 	}
+	c.size += int64(len(content))
+	c.updateMetrics()
+}
+
+func (c *Cache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, cached := range c.files {
+		if strings.HasPrefix(path, prefix) {
+			delete(c.files, path)
+			c.size -= int64(len(cached.content))
+			cacheEvictions.Inc()
+		}
+	}
+	c.updateMetrics()
 }
 
 func (c *Cache) Reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	cacheEvictions.Add(float64(len(c.files)))
 	c.files = make(map[string]cachedFile)
+	c.size = 0
+	c.updateMetrics()
+}
+
+// updateMetrics must be called with c.mu held
+func (c *Cache) updateMetrics() {
+	cacheEntries.Set(float64(len(c.files)))
+	cacheBytes.Set(float64(c.size))
 }
 
 type FileServer struct {
@@ -169,6 +216,7 @@ func (fw *FileWatcher) Watch(ctx context.Context, dirs ...string) error {
 			if !ok {
 				return nil
 			}
+			watcherEvents.WithLabelValues(watcherOpName(event.Op)).Inc()
 			if event.Op&fsnotify.Write == fsnotify.Write {
 				fw.cache.Reset()
 			}
@@ -176,6 +224,7 @@ func (fw *FileWatcher) Watch(ctx context.Context, dirs ...string) error {
 			if !ok {
 				return nil
 			}
+			watcherErrors.Inc()
 			log.Println("Watcher error:", err)
 		}
 	}
@@ -190,7 +239,8 @@ func loadConfig() (*Config, error) {
 
 	username := os.Getenv("AUTH_USERNAME")
 	password := os.Getenv("AUTH_PASSWORD")
-	if username == "" || password == "" {
+	// Services authenticate with client certificates when those are required
+	if (username == "" || password == "") && !appconfig.RequiresClientCert() {
 		log.Fatal("AUTH_USERNAME and AUTH_PASSWORD must be set")
 	}
 
@@ -209,6 +259,29 @@ func loadConfig() (*Config, error) {
 		}
 	}
 
+	pagesBranch := os.Getenv("PAGES_BRANCH")
+	if pagesBranch == "" {
+		pagesBranch = "pages"
+	}
+
+	shutdownTimeout := 30 * time.Second
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		shutdownTimeout = timeout
+	}
+
+	bandwidthLimit := 0
+	if value := os.Getenv("DOWNLOAD_BANDWIDTH_LIMIT"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		bandwidthLimit = limit
+	}
+
 	config := &Config{
 		ServerHost:          serverHost,
 		Username:            username,
@@ -219,6 +292,12 @@ func loadConfig() (*Config, error) {
 		ProjectsDir:         filepath.Join(sharedDataDir, "projects"),
 		ReposDir:            filepath.Join(sharedDataDir, "repos"),
 		ProjectsFinancesDir: filepath.Join(sharedDataDir, "projects/finances"),
+		SitesDir:            filepath.Join(sharedDataDir, "sites"),
+		PagesBranch:         pagesBranch,
+		ShutdownTimeout:     shutdownTimeout,
+		ProxyHeader:         os.Getenv("PROXY_HEADER"),
+		RateLimitRedisURL:   os.Getenv("RATE_LIMIT_REDIS_URL"),
+		BandwidthLimit:      bandwidthLimit,
 	}
 
 	log.Printf("Server will listen on %s", config.ServerHost)
@@ -249,9 +328,31 @@ func ensureDirectories(dirs ...string) error {
 	return nil
 }
 
-func setupRoutes(app *fiber.App, fileServer *FileServer, config *Config) {
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"status": "ok"})
+func setupRoutes(app *fiber.App, fileServer *FileServer, pagesServer *PagesServer, health *HealthChecker, limiter Limiter, limits map[string]MountLimits, config *Config) {
+	app.Use(metricsMiddleware)
+	app.Use(rateLimitMiddleware(limiter, limits, config))
+
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+
+	app.Get("/health", health.Live)
+	app.Get("/health/live", health.Live)
+	app.Get("/health/ready", health.Ready)
+
+	app.Get("/sites/:projectToken/*", func(c *fiber.Ctx) error {
+		// Relative links inside the site only resolve below a trailing slash
+		if c.Params("*") == "" && !strings.HasSuffix(c.Path(), "/") {
+			return c.Redirect(c.Path()+"/", fiber.StatusMovedPermanently)
+		}
+
+		content, ext, status, err := pagesServer.ServeSite(c.Params("projectToken"), c.Params("*"))
+		if err != nil {
+			return err
+		}
+
+		c.Set("Cache-Control", "no-cache")
+		c.Type(ext)
+		c.Status(status)
+		return sendContent(c, content, config.BandwidthLimit)
 	})
 
 	app.Get("/*", func(c *fiber.Ctx) error {
@@ -285,7 +386,7 @@ func setupRoutes(app *fiber.App, fileServer *FileServer, config *Config) {
 		}
 
 		c.Type(ext)
-		return c.Send(content)
+		return sendContent(c, content, config.BandwidthLimit)
 	})
 }
 
@@ -295,7 +396,7 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	if err := ensureDirectories(config.AccountsDir, config.MessagesDir, config.ProjectsDir, config.ReposDir, config.ProjectsFinancesDir); err != nil {
+	if err := ensureDirectories(config.AccountsDir, config.MessagesDir, config.ProjectsDir, config.ReposDir, config.ProjectsFinancesDir, config.SitesDir); err != nil {
 		log.Fatalf("Failed to create directories: %v", err)
 	}
 
@@ -306,17 +407,60 @@ func main() {
 		log.Fatalf("Failed to initialize file server: %v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	pagesServer := NewPagesServer(cache, config.ReposDir, config.SitesDir, config.PagesBranch)
+
+	// Only connect when the database is configured, the file server works without it
+	var db *sql.DB
+	if os.Getenv("POSTGRESQL_HOST") != "" {
+		db, err = appconfig.OpenDB()
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+	}
+
+	health := NewHealthChecker(db,
+		map[string]string{
+			"accounts": config.AccountsDir,
+			"messages": config.MessagesDir,
+			"projects": config.ProjectsDir,
+			"repos":    config.ReposDir,
+		},
+		map[string]string{
+			"sites": config.SitesDir,
+		},
+	)
+
+	limits, err := loadRateLimits([]string{"accounts", "messages", "projects", "sites"})
+	if err != nil {
+		log.Fatalf("Failed to load rate limits: %v", err)
+	}
+
+	var limiter Limiter = NewMemoryLimiter()
+	if config.RateLimitRedisURL != "" {
+		redisLimiter, err := NewRedisLimiter(config.RateLimitRedisURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to rate limit Redis: %v", err)
+		}
+		defer redisLimiter.Close()
+		limiter = redisLimiter
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	watcher := NewFileWatcher(cache)
+	watcherDone := make(chan struct{})
 	go func() {
+		defer close(watcherDone)
 		if err := watcher.Watch(ctx, config.AccountsDir, config.MessagesDir); err != nil {
 			log.Printf("Watcher stopped: %v", err)
 		}
 	}()
 
 	app := fiber.New(fiber.Config{
+		// Only set behind a reverse proxy, otherwise clients can pick their own IP
+		ProxyHeader: config.ProxyHeader,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -326,10 +470,45 @@ func main() {
 		},
 	})
 
-	setupRoutes(app, fileServer, config)
+	setupRoutes(app, fileServer, pagesServer, health, limiter, limits, config)
 
-	log.Printf("Server starting on %s", config.ServerHost)
-	if err := app.Listen(config.ServerHost); err != nil {
-		log.Fatalf("Server error: %v", err)
+	tlsConfig, err := appconfig.LoadTLSConfig()
+	if err != nil {
+		log.Fatalf("Failed to load TLS config: %v", err)
 	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if tlsConfig == nil {
+			log.Printf("Server starting on %s", config.ServerHost)
+			serverErr <- app.Listen(config.ServerHost)
+			return
+		}
+
+		ln, err := tls.Listen("tcp", config.ServerHost, tlsConfig)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		log.Printf("Server starting with TLS on %s", config.ServerHost)
+		serverErr <- app.Listener(ln)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+	case <-ctx.Done():
+		log.Printf("Shutting down, waiting up to %s for in-flight requests", config.ShutdownTimeout)
+		health.SetDraining()
+
+		if err := app.ShutdownWithTimeout(config.ShutdownTimeout); err != nil {
+			log.Printf("Graceful shutdown failed: %v", err)
+		}
+	}
+
+	stop()
+	<-watcherDone
+	log.Println("Server stopped")
 }
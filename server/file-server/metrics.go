@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Mount names used as metric labels. Anything else is reported as "other" so
+// raw paths and tokens never end up in label values.
+var metricMounts = []string{"accounts", "messages", "projects", "sites", "health", "metrics"}
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "file_server",
+		Name:      "http_requests_total",
+		Help:      "Handled HTTP requests by mount and status code.",
+	}, []string{"mount", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "file_server",
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by mount and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"mount", "status"})
+
+	httpResponseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "file_server",
+		Name:      "http_response_bytes_total",
+		Help:      "Bytes served in response bodies by mount.",
+	}, []string{"mount"})
+
+	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "file_server",
+		Name:      "cache_hits_total",
+		Help:      "File cache lookups that found an entry.",
+	})
+
+	cacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "file_server",
+		Name:      "cache_misses_total",
+		Help:      "File cache lookups that found no entry.",
+	})
+
+	cacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "file_server",
+		Name:      "cache_evictions_total",
+		Help:      "Entries dropped from the file cache.",
+	})
+
+	cacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "file_server",
+		Name:      "cache_entries",
+		Help:      "Files currently held in the cache.",
+	})
+
+	cacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "file_server",
+		Name:      "cache_size_bytes",
+		Help:      "Total size of the files currently held in the cache.",
+	})
+
+	watcherEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "file_server",
+		Name:      "watcher_events_total",
+		Help:      "Filesystem events received by the watcher by operation.",
+	}, []string{"op"})
+
+	watcherErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "file_server",
+		Name:      "watcher_errors_total",
+		Help:      "Errors reported by the filesystem watcher.",
+	})
+)
+
+func mountName(path string) string {
+	segment := strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(segment, '/'); i >= 0 {
+		segment = segment[:i]
+	}
+
+	for _, mount := range metricMounts {
+		if segment == mount {
+			return mount
+		}
+	}
+	return "other"
+}
+
+func watcherOpName(op fsnotify.Op) string {
+	switch {
+	case op.Has(fsnotify.Create):
+		return "create"
+	case op.Has(fsnotify.Write):
+		return "write"
+	case op.Has(fsnotify.Remove):
+		return "remove"
+	case op.Has(fsnotify.Rename):
+		return "rename"
+	case op.Has(fsnotify.Chmod):
+		return "chmod"
+	}
+	return "other"
+}
+
+// metricsMiddleware records request count, latency and response size.
+func metricsMiddleware(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	// Errors are turned into responses by the app's ErrorHandler after the
+	// middleware returns, so derive the status code the same way it does
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+	}
+
+	mount := mountName(c.Path())
+	statusLabel := strconv.Itoa(status)
+
+	httpRequests.WithLabelValues(mount, statusLabel).Inc()
+	httpRequestDuration.WithLabelValues(mount, statusLabel).Observe(time.Since(start).Seconds())
+
+	if err == nil {
+		if size := c.Response().Header.ContentLength(); size > 0 {
+			httpResponseBytes.WithLabelValues(mount).Add(float64(size))
+		} else if !c.Response().IsBodyStream() {
+			httpResponseBytes.WithLabelValues(mount).Add(float64(len(c.Response().Body())))
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMountName(t *testing.T) {
+	tests := map[string]string{
+		"/projects/3f2a9c/releases/app.zip": "projects",
+		"/accounts":                         "accounts",
+		"/sites/3f2a9c/":                    "sites",
+		"/health/ready":                     "health",
+		"/metrics":                          "metrics",
+		"/":                                 "other",
+		"/3f2a9c4d5e6f708192a3/secret":      "other",
+		"/projectsX/file":                   "other",
+		"//projects/file":                   "other",
+	}
+
+	for path, want := range tests {
+		if got := mountName(path); got != want {
+			t.Errorf("mountName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// metricLabels returns the values of label on the series of a metric
+func metricLabels(t *testing.T, metricName string, label string) []string {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+	for _, family := range families {
+		if family.GetName() != metricName {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == label && !slices.Contains(values, pair.GetValue()) {
+					values = append(values, pair.GetValue())
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestMetricsMiddlewareBoundsMountLabels(t *testing.T) {
+	app := fiber.New()
+	app.Use(metricsMiddleware)
+	app.Get("/projects/*", func(c *fiber.Ctx) error {
+		return c.SendString("content")
+	})
+	app.Get("/sites/*", func(c *fiber.Ctx) error {
+		return fiber.ErrNotFound
+	})
+
+	paths := []string{
+		"/projects/3f2a9c/file.txt",
+		"/sites/3f2a9c/missing.html",
+		"/3f2a9c4d5e6f708192a3b4c5d6e7f80912a3b4c5/file.txt",
+		"/random-token/../projects",
+		"/%2e%2e/etc/passwd",
+	}
+	for _, path := range paths {
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	allowed := append(slices.Clone(metricMounts), "other")
+	for _, metricName := range []string{"file_server_http_requests_total", "file_server_http_request_duration_seconds", "file_server_http_response_bytes_total"} {
+		mounts := metricLabels(t, metricName, "mount")
+		if len(mounts) == 0 {
+			t.Errorf("%s has no series", metricName)
+		}
+		for _, mount := range mounts {
+			if !slices.Contains(allowed, mount) {
+				t.Errorf("%s has mount label %q", metricName, mount)
+			}
+		}
+	}
+
+	statuses := metricLabels(t, "file_server_http_requests_total", "status")
+	for _, want := range []string{"200", "404"} {
+		if !slices.Contains(statuses, want) {
+			t.Errorf("status labels = %v, want %s among them", statuses, want)
+		}
+	}
+}
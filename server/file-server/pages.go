@@ -0,0 +1,357 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// How long a resolved branch head is trusted before asking git again.
+const pagesHeadTTL = 2 * time.Second
+
+// Optional file in the root of the pages branch that tunes how the site is served.
+const pagesConfigFile = ".pages.json"
+
+var projectTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+type siteConfig struct {
+	// Serve index.html for unknown extension-less paths (client side routing)
+	SPA bool `json:"spa"`
+}
+
+type siteHead struct {
+	commit    string
+	checkedAt time.Time
+}
+
+// PagesServer serves the tree of a project's pages branch as a static site.
+// Every commit is extracted into its own directory, so a push only becomes
+// visible once its tree is fully on disk.
+type PagesServer struct {
+	cache    *Cache
+	reposDir string
+	sitesDir string
+	branch   string
+
+	mu         sync.Mutex
+	heads      map[string]siteHead
+	configs    map[string]siteConfig
+	extracting map[string]*sync.Mutex
+}
+
+func NewPagesServer(cache *Cache, reposDir, sitesDir, branch string) *PagesServer {
+	return &PagesServer{
+		cache:      cache,
+		reposDir:   reposDir,
+		sitesDir:   sitesDir,
+		branch:     branch,
+		heads:      make(map[string]siteHead),
+		configs:    make(map[string]siteConfig),
+		extracting: make(map[string]*sync.Mutex),
+	}
+}
+
+// ServeSite returns the content for requestedPath inside the site of projectToken
+// together with its extension and the status code to answer with.
+func (ps *PagesServer) ServeSite(projectToken, requestedPath string) ([]byte, string, int, error) {
+	if !projectTokenPattern.MatchString(projectToken) {
+		return nil, "", 0, fiber.ErrNotFound
+	}
+
+	root, err := ps.siteRoot(projectToken)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	relativePath := path.Clean("/" + requestedPath)
+	if relativePath == "/"+pagesConfigFile {
+		return ps.notFound(root, relativePath)
+	}
+
+	filePath := filepath.Join(root, filepath.FromSlash(relativePath))
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		filePath = filepath.Join(filePath, "index.html")
+	}
+
+	content, err := ps.readFile(filePath)
+	if err == nil {
+		return content, filepath.Ext(filePath), fiber.StatusOK, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, "", 0, fiber.ErrInternalServerError
+	}
+
+	return ps.notFound(root, relativePath)
+}
+
+func (ps *PagesServer) notFound(root, relativePath string) ([]byte, string, int, error) {
+	if ps.config(root).SPA && path.Ext(relativePath) == "" {
+		if content, err := ps.readFile(filepath.Join(root, "index.html")); err == nil {
+			return content, ".html", fiber.StatusOK, nil
+		}
+	}
+
+	if content, err := ps.readFile(filepath.Join(root, "404.html")); err == nil {
+		return content, ".html", fiber.StatusNotFound, nil
+	}
+
+	return nil, "", 0, fiber.ErrNotFound
+}
+
+// The extracted tree of a commit never changes, so cached entries stay valid
+// for as long as the commit directory exists.
+func (ps *PagesServer) readFile(filePath string) ([]byte, error) {
+	if content, _, exists := ps.cache.Get(filePath); exists {
+		return content, nil
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, os.ErrNotExist
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.cache.Set(filePath, content)
+	return content, nil
+}
+
+func (ps *PagesServer) config(root string) siteConfig {
+	ps.mu.Lock()
+	cfg, exists := ps.configs[root]
+	ps.mu.Unlock()
+	if exists {
+		return cfg
+	}
+
+	if content, err := os.ReadFile(filepath.Join(root, pagesConfigFile)); err == nil {
+		if err := json.Unmarshal(content, &cfg); err != nil {
+			log.Printf("Warning: Invalid %s in %s: %v", pagesConfigFile, root, err)
+		}
+	}
+
+	ps.mu.Lock()
+	ps.configs[root] = cfg
+	ps.mu.Unlock()
+
+	return cfg
+}
+
+// siteRoot resolves the pages branch of the project and makes sure the tree
+// of its head commit is extracted.
+func (ps *PagesServer) siteRoot(projectToken string) (string, error) {
+	commit, err := ps.resolveHead(projectToken)
+	if err != nil {
+		return "", err
+	}
+
+	root := filepath.Join(ps.sitesDir, projectToken, commit)
+	if _, err := os.Stat(root); err == nil {
+		return root, nil
+	}
+
+	lock := ps.extractLock(projectToken)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Another request may have finished the extraction while we waited
+	if _, err := os.Stat(root); err == nil {
+		return root, nil
+	}
+
+	if err := ps.extract(projectToken, commit, root); err != nil {
+		log.Printf("Failed to extract site %s@%s: %v", projectToken, commit, err)
+		return "", fiber.ErrInternalServerError
+	}
+
+	ps.prune(projectToken, commit)
+	return root, nil
+}
+
+func (ps *PagesServer) resolveHead(projectToken string) (string, error) {
+	ps.mu.Lock()
+	head, exists := ps.heads[projectToken]
+	ps.mu.Unlock()
+	if exists && time.Since(head.checkedAt) < pagesHeadTTL {
+		return head.commit, nil
+	}
+
+	gitDir := filepath.Join(ps.reposDir, projectToken+".git")
+	if _, err := os.Stat(gitDir); err != nil {
+		return "", fiber.ErrNotFound
+	}
+
+	ref := fmt.Sprintf("refs/heads/%s^{commit}", ps.branch)
+	output, err := exec.Command("git", "--git-dir="+gitDir, "rev-parse", "--verify", "--quiet", ref).Output()
+	if err != nil {
+		return "", fiber.ErrNotFound
+	}
+
+	commit := strings.TrimSpace(string(output))
+
+	ps.mu.Lock()
+	ps.heads[projectToken] = siteHead{commit: commit, checkedAt: time.Now()}
+	ps.mu.Unlock()
+
+	return commit, nil
+}
+
+func (ps *PagesServer) extractLock(projectToken string) *sync.Mutex {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	lock, exists := ps.extracting[projectToken]
+	if !exists {
+		lock = &sync.Mutex{}
+		ps.extracting[projectToken] = lock
+	}
+	return lock
+}
+
+// extract unpacks the tree of commit into a temporary directory and renames
+// it into place once complete.
+func (ps *PagesServer) extract(projectToken, commit, root string) error {
+	siteDir := filepath.Dir(root)
+	if err := os.MkdirAll(siteDir, 0755); err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp(siteDir, ".extract-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	gitDir := filepath.Join(ps.reposDir, projectToken+".git")
+	cmd := exec.Command("git", "--git-dir="+gitDir, "archive", "--format=tar", commit)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if err := untar(stdout, tmpDir); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive failed: %w", err)
+	}
+
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpDir, root)
+}
+
+// untar writes regular files and directories from r below dst. Links and
+// entries that would land outside dst are skipped.
+func untar(r io.Reader, dst string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + header.Name)
+		if name == "/" {
+			continue
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(file, tr); err != nil {
+				file.Close()
+				return err
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// prune removes the extracted trees of older commits of a site. The tree
+// extracted before keepCommit is kept as well, requests that resolved the
+// previous head may still be reading from it.
+func (ps *PagesServer) prune(projectToken, keepCommit string) {
+	siteDir := filepath.Join(ps.sitesDir, projectToken)
+
+	entries, err := os.ReadDir(siteDir)
+	if err != nil {
+		return
+	}
+
+	var previous []os.FileInfo
+	for _, entry := range entries {
+		if entry.Name() == keepCommit || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			previous = append(previous, info)
+		}
+	}
+
+	// Trees are renamed into place once extracted, the newest one is the
+	// commit served until now
+	sort.Slice(previous, func(i, j int) bool {
+		return previous[i].ModTime().After(previous[j].ModTime())
+	})
+	if len(previous) > 0 {
+		previous = previous[1:]
+	}
+
+	for _, info := range previous {
+		root := filepath.Join(siteDir, info.Name())
+		if err := os.RemoveAll(root); err != nil {
+			log.Printf("Warning: Could not remove old site tree %s: %v", root, err)
+		}
+
+		ps.cache.DeletePrefix(root + string(filepath.Separator))
+
+		ps.mu.Lock()
+		delete(ps.configs, root)
+		ps.mu.Unlock()
+	}
+}
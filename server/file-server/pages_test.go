@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testSiteToken = "3f2a9c4d5e6f708192a3b4c5d6e7f80912a3b4c5"
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     0644,
+			Size:     int64(len(entry.content)),
+		}
+		if entry.typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if entry.typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(entry.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestUntarStaysInsideDestination(t *testing.T) {
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "site")
+	if err := os.Mkdir(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := buildTar(t, []tarEntry{
+		{name: "index.html", typeflag: tar.TypeReg, content: "home"},
+		{name: "assets/", typeflag: tar.TypeDir},
+		{name: "assets/app.js", typeflag: tar.TypeReg, content: "app"},
+		{name: "../escaped.txt", typeflag: tar.TypeReg, content: "outside"},
+		{name: "assets/../../../escaped-deep.txt", typeflag: tar.TypeReg, content: "outside"},
+		{name: "/absolute.txt", typeflag: tar.TypeReg, content: "absolute"},
+		{name: "link-out", typeflag: tar.TypeSymlink, linkname: "../../etc/passwd"},
+		{name: "link-in", typeflag: tar.TypeSymlink, linkname: "index.html"},
+		{name: "hard-link", typeflag: tar.TypeLink, linkname: "index.html"},
+	})
+
+	if err := untar(archive, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		"index.html":    "home",
+		"assets/app.js": "app",
+		// Leading "../" and "/" are cleaned away, the entry lands inside
+		"escaped.txt":      "outside",
+		"escaped-deep.txt": "outside",
+		"absolute.txt":     "absolute",
+	} {
+		content, err := os.ReadFile(filepath.Join(dst, path))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+
+	for _, name := range []string{"escaped.txt", "escaped-deep.txt"} {
+		if _, err := os.Lstat(filepath.Join(parent, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was written outside the destination", name)
+		}
+	}
+
+	for _, name := range []string{"link-out", "link-in", "hard-link"} {
+		if _, err := os.Lstat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+			t.Errorf("link %s was extracted", name)
+		}
+	}
+}
+
+func TestPruneKeepsCurrentAndPreviousTrees(t *testing.T) {
+	sitesDir := t.TempDir()
+	cache := NewCache()
+	ps := NewPagesServer(cache, t.TempDir(), sitesDir, "pages")
+
+	siteDir := filepath.Join(sitesDir, testSiteToken)
+	now := time.Now()
+	trees := map[string]time.Time{
+		"oldest":   now.Add(-3 * time.Hour),
+		"older":    now.Add(-2 * time.Hour),
+		"previous": now.Add(-time.Hour),
+		"current":  now.Add(-4 * time.Hour),
+	}
+	for name, modTime := range trees {
+		root := filepath.Join(siteDir, name)
+		if err := os.MkdirAll(root, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "index.html"), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(root, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		cache.Set(filepath.Join(root, "index.html"), []byte(name))
+	}
+
+	// An extraction in progress
+	if err := os.MkdirAll(filepath.Join(siteDir, ".extract-123"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ps.prune(testSiteToken, "current")
+
+	for name, kept := range map[string]bool{
+		"current":      true,
+		"previous":     true,
+		"older":        false,
+		"oldest":       false,
+		".extract-123": true,
+	} {
+		_, err := os.Stat(filepath.Join(siteDir, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s exists = %v, want %v", name, exists, kept)
+		}
+
+		if name == ".extract-123" {
+			continue
+		}
+		_, _, cached := cache.Get(filepath.Join(siteDir, name, "index.html"))
+		if cached != kept {
+			t.Errorf("%s cached = %v, want %v", name, cached, kept)
+		}
+	}
+}
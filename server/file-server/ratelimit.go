@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Idle buckets are dropped from memory after this long without requests.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit is a token bucket refilled with Rate tokens per second that holds
+// at most Burst tokens.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// MountLimits holds the limits of one mount for anonymous clients, keyed by
+// IP, and for authenticated clients, keyed by user.
+type MountLimits struct {
+	IP   *RateLimit
+	User *RateLimit
+}
+
+// Limiter takes a token from the bucket of key. When none is left it reports
+// how long the client has to wait for the next one.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), updated: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.Rate)
+	bucket.updated = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0, nil
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	return false, wait, nil
+}
+
+// sweep must be called with l.mu held
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.updated) > rateLimitSweepInterval {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// The bucket is refilled and consumed atomically inside Redis, using the Redis
+// clock so all replicas agree on elapsed time.
+var redisTokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + (now - updated) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+
+return {allowed, wait}
+`)
+
+// RedisLimiter shares buckets between replicas of the file server.
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisLimiter(redisURL string) (*RedisLimiter, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisLimiter{client: client}, nil
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	result, err := redisTokenBucket.Run(ctx, l.client, []string{"file-server:ratelimit:" + key}, limit.Rate, limit.Burst).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit script result: %v", result)
+	}
+
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+func (l *RedisLimiter) Close() error {
+	return l.client.Close()
+}
+
+// parseRateLimit reads limits written as "<requests per second>/<burst>",
+// for example "10/20".
+func parseRateLimit(value string) (*RateLimit, error) {
+	rateValue, burstValue, found := strings.Cut(value, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid rate limit %q, expected <rate>/<burst>", value)
+	}
+
+	rate, err := strconv.ParseFloat(strings.TrimSpace(rateValue), 64)
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("invalid rate in rate limit %q", value)
+	}
+
+	burst, err := strconv.Atoi(strings.TrimSpace(burstValue))
+	if err != nil || burst < 1 {
+		return nil, fmt.Errorf("invalid burst in rate limit %q", value)
+	}
+
+	return &RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// loadRateLimits reads RATE_LIMIT_<MOUNT> (per IP) and RATE_LIMIT_<MOUNT>_USER
+// (per authenticated user) for every mount. RATE_LIMIT_DEFAULT and
+// RATE_LIMIT_DEFAULT_USER apply to mounts without their own setting.
+func loadRateLimits(mounts []string) (map[string]MountLimits, error) {
+	lookup := func(name string) (*RateLimit, error) {
+		value := os.Getenv(name)
+		if value == "" {
+			return nil, nil
+		}
+		return parseRateLimit(value)
+	}
+
+	defaultIP, err := lookup("RATE_LIMIT_DEFAULT")
+	if err != nil {
+		return nil, err
+	}
+	defaultUser, err := lookup("RATE_LIMIT_DEFAULT_USER")
+	if err != nil {
+		return nil, err
+	}
+
+	limits := make(map[string]MountLimits)
+	for _, mount := range mounts {
+		prefix := "RATE_LIMIT_" + strings.ToUpper(mount)
+
+		ipLimit, err := lookup(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if ipLimit == nil {
+			ipLimit = defaultIP
+		}
+
+		userLimit, err := lookup(prefix + "_USER")
+		if err != nil {
+			return nil, err
+		}
+		if userLimit == nil {
+			userLimit = defaultUser
+		}
+
+		if ipLimit != nil || userLimit != nil {
+			limits[mount] = MountLimits{IP: ipLimit, User: userLimit}
+			log.Printf("Rate limits for /%s/: ip=%v user=%v", mount, ipLimit, userLimit)
+		}
+	}
+
+	return limits, nil
+}
+
+func (l *RateLimit) String() string {
+	if l == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%g/s burst %d", l.Rate, l.Burst)
+}
+
+// authenticatedUser returns the user of a verified client certificate or of
+// valid basic auth credentials, or an empty string for anonymous requests.
+func authenticatedUser(c *fiber.Ctx, config *Config) string {
+	// Client certificates are only present once verified against the client CA
+	if state := c.Context().TLSConnectionState(); state != nil && len(state.VerifiedChains) > 0 {
+		return "cert:" + state.PeerCertificates[0].Subject.CommonName
+	}
+
+	auth := c.Get(fiber.HeaderAuthorization)
+	if config.Username == "" || !strings.HasPrefix(auth, "Basic ") {
+		return ""
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+	if err != nil {
+		return ""
+	}
+
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return ""
+	}
+
+	if subtle.ConstantTimeCompare([]byte(username), []byte(config.Username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(config.Password)) != 1 {
+		return ""
+	}
+
+	return username
+}
+
+// rateLimitMiddleware answers 429 with Retry-After once the client used up
+// the bucket of the mount it requests. Limiter failures let requests through.
+func rateLimitMiddleware(limiter Limiter, limits map[string]MountLimits, config *Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		mount := mountName(c.Path())
+
+		mountLimits, exists := limits[mount]
+		if !exists {
+			return c.Next()
+		}
+
+		var key string
+		var limit *RateLimit
+		if user := authenticatedUser(c, config); user != "" {
+			key = fmt.Sprintf("%s:user:%s", mount, user)
+			limit = mountLimits.User
+		} else {
+			key = fmt.Sprintf("%s:ip:%s", mount, c.IP())
+			limit = mountLimits.IP
+		}
+
+		if limit == nil {
+			return c.Next()
+		}
+
+		allowed, wait, err := limiter.Allow(c.UserContext(), key, *limit)
+		if err != nil {
+			log.Printf("Rate limiter error: %v", err)
+			return c.Next()
+		}
+
+		if !allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return fiber.ErrTooManyRequests
+		}
+
+		return c.Next()
+	}
+}
+
+// throttledReader paces reads so that no more than bytesPerSecond are
+// delivered on average.
+type throttledReader struct {
+	reader         io.Reader
+	bytesPerSecond int
+	start          time.Time
+	read           int64
+}
+
+func newThrottledReader(reader io.Reader, bytesPerSecond int) *throttledReader {
+	return &throttledReader{
+		reader:         reader,
+		bytesPerSecond: bytesPerSecond,
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	// Small chunks keep the pace smooth instead of bursting a second's worth
+	chunk := t.bytesPerSecond / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if len(p) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := t.reader.Read(p)
+	t.read += int64(n)
+
+	expected := time.Duration(float64(t.read) / float64(t.bytesPerSecond) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return n, err
+}
+
+// sendContent sends content, throttled to bandwidthLimit bytes per second
+// when it is larger than what can be sent within one second.
+func sendContent(c *fiber.Ctx, content []byte, bandwidthLimit int) error {
+	if bandwidthLimit <= 0 || len(content) <= bandwidthLimit {
+		return c.Send(content)
+	}
+
+	return c.SendStream(newThrottledReader(bytes.NewReader(content), bandwidthLimit), len(content))
+}
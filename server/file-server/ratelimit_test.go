@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMemoryLimiterRefillsOverTime(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	limit := RateLimit{Rate: 2, Burst: 3}
+	ctx := context.Background()
+
+	for i := 0; i < limit.Burst; i++ {
+		if allowed, _, _ := limiter.Allow(ctx, "client", limit); !allowed {
+			t.Fatalf("request %d within the burst was rejected", i+1)
+		}
+	}
+
+	allowed, wait, err := limiter.Allow(ctx, "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed {
+		t.Fatal("request beyond the burst was allowed")
+	}
+	// One token takes 1/Rate seconds to refill
+	if wait <= 0 || wait > 500*time.Millisecond {
+		t.Errorf("wait = %v, want at most 500ms", wait)
+	}
+
+	if allowed, _, _ := limiter.Allow(ctx, "other-client", limit); !allowed {
+		t.Error("another client shares the bucket")
+	}
+
+	// Backdate the bucket instead of sleeping: one second refills two tokens
+	limiter.mu.Lock()
+	limiter.buckets["client"].updated = limiter.buckets["client"].updated.Add(-time.Second)
+	limiter.mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := limiter.Allow(ctx, "client", limit); !allowed {
+			t.Fatalf("refilled token %d was rejected", i+1)
+		}
+	}
+	if allowed, _, _ := limiter.Allow(ctx, "client", limit); allowed {
+		t.Error("more tokens than refilled were handed out")
+	}
+
+	// An idle bucket never holds more than the burst
+	limiter.mu.Lock()
+	limiter.buckets["client"].updated = limiter.buckets["client"].updated.Add(-time.Hour)
+	limiter.mu.Unlock()
+
+	for i := 0; i < limit.Burst; i++ {
+		if allowed, _, _ := limiter.Allow(ctx, "client", limit); !allowed {
+			t.Fatalf("request %d after idling was rejected", i+1)
+		}
+	}
+	if allowed, _, _ := limiter.Allow(ctx, "client", limit); allowed {
+		t.Error("bucket refilled beyond its burst")
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := parseRateLimit(" 0.5 / 10 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit.Rate != 0.5 || limit.Burst != 10 {
+		t.Errorf("limit = %+v, want 0.5/s burst 10", limit)
+	}
+
+	for _, value := range []string{"10", "0/5", "-1/5", "1/0", "x/5", "1/x"} {
+		if _, err := parseRateLimit(value); err == nil {
+			t.Errorf("parseRateLimit(%q) succeeded", value)
+		}
+	}
+}
+
+// fixedLimiter rejects every request with the same wait
+type fixedLimiter struct {
+	wait time.Duration
+	keys []string
+}
+
+func (l *fixedLimiter) Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	l.keys = append(l.keys, key)
+	return false, l.wait, nil
+}
+
+func newRateLimitedApp(limiter Limiter, limits map[string]MountLimits, config *Config) *fiber.App {
+	app := fiber.New()
+	app.Use(rateLimitMiddleware(limiter, limits, config))
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	return app
+}
+
+func TestRateLimitMiddlewareSetsRetryAfter(t *testing.T) {
+	limits := map[string]MountLimits{"projects": {IP: &RateLimit{Rate: 1, Burst: 1}}}
+	app := newRateLimitedApp(NewMemoryLimiter(), limits, &Config{})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/projects/file.txt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("first request: status %d", resp.StatusCode)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/projects/file.txt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", resp.StatusCode)
+	}
+	if retryAfter := resp.Header.Get(fiber.HeaderRetryAfter); retryAfter != "1" {
+		t.Errorf("Retry-After = %q, want 1", retryAfter)
+	}
+
+	// Mounts without limits are not counted
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/accounts/avatar.png", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("unlimited mount: status %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimitMiddlewareRoundsRetryAfterUp(t *testing.T) {
+	limiter := &fixedLimiter{wait: 1500 * time.Millisecond}
+	limits := map[string]MountLimits{
+		"projects": {IP: &RateLimit{Rate: 1, Burst: 1}, User: &RateLimit{Rate: 5, Burst: 5}},
+	}
+	app := newRateLimitedApp(limiter, limits, &Config{Username: "admin", Password: "secret"})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/projects/file.txt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter := resp.Header.Get(fiber.HeaderRetryAfter); retryAfter != "2" {
+		t.Errorf("Retry-After = %q, want 2 for a 1.5s wait", retryAfter)
+	}
+
+	req := httptest.NewRequest(fiber.MethodGet, "/projects/file.txt", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:secret")))
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest(fiber.MethodGet, "/projects/file.txt", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:wrong")))
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"projects:ip:0.0.0.0", "projects:user:admin", "projects:ip:0.0.0.0"}
+	if len(limiter.keys) != len(want) {
+		t.Fatalf("keys = %v, want %v", limiter.keys, want)
+	}
+	for i := range want {
+		if limiter.keys[i] != want[i] {
+			t.Errorf("keys = %v, want %v", limiter.keys, want)
+			break
+		}
+	}
+}
@@ -9,8 +9,8 @@ import (
 	_ "github.com/lib/pq"
 )
 
-func InitDB() (*sql.DB, error) {
-
+// DataSourceName builds the Postgres connection string from the environment
+func DataSourceName() string {
 	// Access the environment variables
 	dbHost := os.Getenv("POSTGRESQL_HOST")
 	dbPort := os.Getenv("POSTGRESQL_PORT")
@@ -19,13 +19,18 @@ func InitDB() (*sql.DB, error) {
 	dbName := os.Getenv("POSTGRESQL_DB")
 
 	// Construct the data source name
-	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		dbHost,
 		dbPort,
 		dbUser,
 		dbPass,
 		dbName,
 	)
+}
+
+func InitDB() (*sql.DB, error) {
+
+	dataSourceName := DataSourceName()
 
 	// Open a database connection
 	db, err := sql.Open("postgres", dataSourceName)
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// How often the certificate files are checked for changes at most.
+const tlsReloadInterval = 5 * time.Second
+
+// TLSManager serves the certificate, key and client CA bundle from disk and
+// picks up new versions of those files without a restart.
+type TLSManager struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	clientAuth   tls.ClientAuthType
+
+	mu        sync.Mutex
+	config    *tls.Config
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+// LoadTLSConfig reads TLS_CERT_FILE and TLS_KEY_FILE, and optionally
+// TLS_CLIENT_CA_FILE to verify client certificates. TLS_CLIENT_AUTH selects
+// "require" (default) or "optional" client certificates. It returns nil when
+// TLS is not configured.
+func LoadTLSConfig() (*tls.Config, error) {
+	certFile := os.Getenv("TLS_CERT_FILE")
+	keyFile := os.Getenv("TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" {
+		// Client certificates cannot be verified over plaintext
+		if os.Getenv("TLS_CLIENT_CA_FILE") != "" {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	manager := &TLSManager{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		clientAuth:   tls.NoClientCert,
+	}
+
+	if manager.clientCAFile != "" {
+		switch os.Getenv("TLS_CLIENT_AUTH") {
+		case "", "require":
+			manager.clientAuth = tls.RequireAndVerifyClientCert
+		case "optional":
+			manager.clientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("TLS_CLIENT_AUTH must be \"require\" or \"optional\"")
+		}
+	}
+
+	if err := manager.reload(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: manager.getConfigForClient,
+	}, nil
+}
+
+func (m *TLSManager) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if time.Since(m.checkedAt) > tlsReloadInterval {
+		m.checkedAt = time.Now()
+		if m.changed() {
+			if err := m.reloadLocked(); err != nil {
+				// Keep serving the previous certificate until the files are valid again
+				log.Printf("Failed to reload TLS certificates: %v", err)
+			} else {
+				log.Println("Reloaded TLS certificates")
+			}
+		}
+	}
+
+	return m.config, nil
+}
+
+func (m *TLSManager) files() []string {
+	files := []string{m.certFile, m.keyFile}
+	if m.clientCAFile != "" {
+		files = append(files, m.clientCAFile)
+	}
+	return files
+}
+
+// changed must be called with m.mu held
+func (m *TLSManager) changed() bool {
+	for _, file := range m.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(m.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *TLSManager) reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkedAt = time.Now()
+	return m.reloadLocked()
+}
+
+// reloadLocked must be called with m.mu held
+func (m *TLSManager) reloadLocked() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range m.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(m.certFile, m.keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load key pair: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   m.clientAuth,
+	}
+
+	if m.clientCAFile != "" {
+		pem, err := os.ReadFile(m.clientCAFile)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", m.clientCAFile)
+		}
+		config.ClientCAs = pool
+	}
+
+	m.config = config
+	m.modTimes = modTimes
+	return nil
+}
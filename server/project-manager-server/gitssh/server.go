@@ -0,0 +1,328 @@
+// Package gitssh serves the hosted repositories to git clients over SSH.
+package gitssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"project-manager-server/lib"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Key of the user's private token in the connection permissions
+const userExtension = "userprivatetoken"
+
+// Repository names are project tokens, optionally with the .git suffix
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+type Server struct {
+	db       *sql.DB
+	config   *ssh.ServerConfig
+	reposDir string
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*ssh.ServerConn]struct{}
+	closing  bool
+	wg       sync.WaitGroup
+}
+
+// NewServer loads the host key from hostKeyPath and generates an ed25519 key
+// there when the file does not exist yet
+func NewServer(db *sql.DB, hostKeyPath string, reposDir string) (*Server, error) {
+	hostKey, err := loadHostKey(hostKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		db:       db,
+		reposDir: reposDir,
+		conns:    make(map[*ssh.ServerConn]struct{}),
+	}
+
+	server.config = &ssh.ServerConfig{
+		PublicKeyCallback: server.authenticate,
+		ServerVersion:     "SSH-2.0-project-manager",
+	}
+	server.config.AddHostKey(hostKey)
+
+	return server, nil
+}
+
+func loadHostKey(path string) (ssh.Signer, error) {
+	keyPEM, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := ssh.MarshalPrivateKey(privateKey, "project-manager host key")
+		if err != nil {
+			return nil, err
+		}
+
+		keyPEM = pem.EncodeToMemory(block)
+		if err := os.WriteFile(path, keyPEM, 0600); err != nil {
+			return nil, fmt.Errorf("cannot write generated host key: %w", err)
+		}
+		log.Printf("Generated SSH host key at %s", path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKey(keyPEM)
+}
+
+func (s *Server) authenticate(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	userPrivateToken := lib.GetPrivateTokenBySSHKey(s.db, key)
+	if userPrivateToken == "" {
+		return nil, fmt.Errorf("unknown public key for %s", conn.User())
+	}
+
+	return &ssh.Permissions{
+		Extensions: map[string]string{userExtension: userPrivateToken},
+	}, nil
+}
+
+// Serve accepts connections until Shutdown is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closing := s.closing
+			s.mu.Unlock()
+			if closing {
+				return nil
+			}
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+// Shutdown stops accepting connections and waits for running git commands
+// to finish. Connections still open when ctx is done are closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Server) handleConn(netConn net.Conn) {
+	conn, channels, requests, err := ssh.NewServerConn(netConn, s.config)
+	if err != nil {
+		netConn.Close()
+		return
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	go ssh.DiscardRequests(requests)
+
+	userPrivateToken := conn.Permissions.Extensions[userExtension]
+
+	var sessions sync.WaitGroup
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		sessions.Add(1)
+		go func() {
+			defer sessions.Done()
+			s.handleSession(userPrivateToken, channel, channelRequests)
+		}()
+	}
+	sessions.Wait()
+}
+
+func (s *Server) handleSession(userPrivateToken string, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	var env []string
+	for request := range requests {
+		switch request.Type {
+		case "env":
+			// Only the protocol version is passed on to git
+			var variable struct{ Name, Value string }
+			if err := ssh.Unmarshal(request.Payload, &variable); err == nil && variable.Name == "GIT_PROTOCOL" {
+				env = append(env, "GIT_PROTOCOL="+variable.Value)
+			}
+			request.Reply(true, nil)
+
+		case "exec":
+			var command struct{ Value string }
+			if err := ssh.Unmarshal(request.Payload, &command); err != nil {
+				request.Reply(false, nil)
+				return
+			}
+			request.Reply(true, nil)
+
+			exitStatus := s.runGit(userPrivateToken, command.Value, env, channel)
+			channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, exitStatus))
+			return
+
+		case "shell":
+			request.Reply(true, nil)
+			fmt.Fprintln(channel.Stderr(), "Interactive shells are not supported, use git to clone and push.")
+			channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, 1))
+			return
+
+		default:
+			if request.WantReply {
+				request.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// runGit runs the git command requested by the client and returns its exit
+// status
+func (s *Server) runGit(userPrivateToken string, command string, env []string, channel ssh.Channel) uint32 {
+	service, repo, err := parseGitCommand(command)
+	if err != nil {
+		fmt.Fprintf(channel.Stderr(), "%v\n", err)
+		return 1
+	}
+
+	action := "read"
+	if service == "git-receive-pack" {
+		action = "update"
+	}
+
+	projectToken := strings.TrimSuffix(repo, ".git")
+	if !lib.CheckUserPermissions(s.db, userPrivateToken, projectToken, "code", action) {
+		fmt.Fprintf(channel.Stderr(), "You don't have permission to %s this repository\n", action)
+		return 1
+	}
+
+	repoPath, err := lib.RepositoryPath(s.reposDir, projectToken)
+	if err != nil {
+		fmt.Fprintln(channel.Stderr(), "Repository not found")
+		return 1
+	}
+	if _, err := os.Stat(repoPath); err != nil {
+		fmt.Fprintln(channel.Stderr(), "Repository not found")
+		return 1
+	}
+
+	log.Printf("SSH %s on repository: %s", service, repoPath)
+
+	cmd := exec.Command("git", service[4:], repoPath)
+	cmd.Dir = repoPath
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = channel
+	cmd.Stderr = channel.Stderr()
+
+	// Not cmd.Stdin, Wait would block until the client closes its side, which
+	// git clients only do after the command exited
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		fmt.Fprintln(channel.Stderr(), "Failed to start git")
+		return 1
+	}
+
+	if err := cmd.Start(); err != nil {
+		log.Printf("SSH git command error: %v", err)
+		fmt.Fprintln(channel.Stderr(), "Failed to start git")
+		return 1
+	}
+
+	go func() {
+		defer stdin.Close()
+		io.Copy(stdin, channel)
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return uint32(exitErr.ExitCode())
+		}
+		log.Printf("SSH git command error: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// parseGitCommand reads commands such as git-upload-pack '/token.git' as
+// sent by git clients
+func parseGitCommand(command string) (string, string, error) {
+	service, argument, found := strings.Cut(strings.TrimSpace(command), " ")
+	if !found || (service != "git-upload-pack" && service != "git-receive-pack") {
+		return "", "", fmt.Errorf("unsupported command, only git-upload-pack and git-receive-pack are allowed")
+	}
+
+	repo := strings.Trim(strings.TrimSpace(argument), `'"`)
+	repo = strings.TrimPrefix(repo, "/")
+	repo = strings.TrimPrefix(repo, "api/repositories/")
+
+	if !repoNamePattern.MatchString(repo) || repo == "." || repo == ".." {
+		return "", "", fmt.Errorf("invalid repository %q", repo)
+	}
+
+	return service, repo, nil
+}
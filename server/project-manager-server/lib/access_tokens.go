@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"project-manager-server/models"
+
+	"github.com/lib/pq"
+)
+
+// Personal access tokens start with this prefix, which tells them apart from
+// session tokens
+const AccessTokenPrefix = "pmt_"
+
+// Longest lifetime a personal access token can be issued for
+const MaxAccessTokenLifetime = 365 * 24 * time.Hour
+
+var accessTokenScopePattern = regexp.MustCompile(`^[a-z_]+:[a-z_]+$`)
+
+// The "write" action of a scope covers these actions
+var writeScopeActions = []string{"create", "update", "delete"}
+
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// ValidAccessTokenScope checks the "<resource>:<action>" format of a scope
+func ValidAccessTokenScope(scope string) bool {
+	return accessTokenScopePattern.MatchString(scope)
+}
+
+// ScopesAllow reports whether scopes grant action on resource. The scope
+// resource:write grants create, update and delete.
+func ScopesAllow(scopes []string, resource string, action string) bool {
+	for _, scope := range scopes {
+		scopeResource, scopeAction, _ := strings.Cut(scope, ":")
+		if scopeResource != resource {
+			continue
+		}
+		if scopeAction == action {
+			return true
+		}
+		if scopeAction == "write" {
+			for _, writeAction := range writeScopeActions {
+				if writeAction == action {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAccessToken stores a new token of the user and returns it in clear
+// text. Only its hash is stored, so it cannot be shown again.
+func CreateAccessToken(db *sql.DB, userPrivateToken string, request models.CreateAccessTokenRequest) (string, models.AccessToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", models.AccessToken{}, err
+	}
+	token := AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	var projectToken *string
+	if request.ProjectToken != "" {
+		projectToken = &request.ProjectToken
+	}
+
+	accessToken := models.AccessToken{
+		Name:         request.Name,
+		Prefix:       token[:len(AccessTokenPrefix)+6],
+		Scopes:       request.Scopes,
+		ProjectToken: projectToken,
+		ExpiresAt:    request.ExpiresAt,
+	}
+
+	const query = `
+		INSERT INTO personal_access_tokens (userprivatetoken, name, token_hash, token_prefix, scopes, projecttoken, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at;
+	`
+
+	err := db.QueryRow(query, userPrivateToken, accessToken.Name, hashAccessToken(token), accessToken.Prefix,
+		pq.Array(accessToken.Scopes), projectToken, accessToken.ExpiresAt).Scan(&accessToken.ID, &accessToken.CreatedAt)
+	if err != nil {
+		return "", models.AccessToken{}, err
+	}
+
+	return token, accessToken, nil
+}
+
+func ListAccessTokens(db *sql.DB, userPrivateToken string) ([]models.AccessToken, error) {
+	const query = `
+		SELECT id, name, token_prefix, scopes, projecttoken, expires_at, last_used_at, revoked_at, created_at
+		FROM personal_access_tokens
+		WHERE userprivatetoken = $1
+		ORDER BY created_at DESC;
+	`
+
+	rows, err := db.Query(query, userPrivateToken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accessTokens := []models.AccessToken{}
+	for rows.Next() {
+		var accessToken models.AccessToken
+		if err := rows.Scan(&accessToken.ID, &accessToken.Name, &accessToken.Prefix, pq.Array(&accessToken.Scopes),
+			&accessToken.ProjectToken, &accessToken.ExpiresAt, &accessToken.LastUsedAt, &accessToken.RevokedAt,
+			&accessToken.CreatedAt); err != nil {
+			return nil, err
+		}
+		accessTokens = append(accessTokens, accessToken)
+	}
+
+	return accessTokens, rows.Err()
+}
+
+// RevokeAccessToken returns false when the user has no active token with this id
+func RevokeAccessToken(db *sql.DB, userPrivateToken string, id int) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND userprivatetoken = $2 AND revoked_at IS NULL;
+	`, id, userPrivateToken)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ResolveAccessToken returns the user of an active token along with the
+// token's scopes and project restriction, and marks the token as used. It
+// returns nil when the token is unknown, revoked or expired.
+func ResolveAccessToken(db *sql.DB, token string) *models.AuthenticatedUser {
+	user, _ := resolveAccessToken(db, token)
+	return user
+}
+
+// ResolveAccessTokenCredentials is ResolveAccessToken for git clients, which
+// also send the email of the token's owner
+func ResolveAccessTokenCredentials(db *sql.DB, email string, token string) *models.AuthenticatedUser {
+	user, userEmail := resolveAccessToken(db, token)
+	if user == nil || !strings.EqualFold(userEmail, email) {
+		return nil
+	}
+	return user
+}
+
+func resolveAccessToken(db *sql.DB, token string) (*models.AuthenticatedUser, string) {
+	if !IsAccessToken(token) {
+		return nil, ""
+	}
+
+	const query = `
+		UPDATE personal_access_tokens t SET last_used_at = CURRENT_TIMESTAMP
+		FROM users u
+		WHERE u.UserPrivateToken = t.userprivatetoken
+		AND t.token_hash = $1
+		AND t.revoked_at IS NULL
+		AND t.expires_at > CURRENT_TIMESTAMP
+		RETURNING t.id, t.userprivatetoken, t.scopes, COALESCE(t.projecttoken, ''), u.UserEmail;
+	`
+
+	user := &models.AuthenticatedUser{}
+	var email string
+	err := db.QueryRow(query, hashAccessToken(token)).Scan(&user.AccessTokenID, &user.PrivateToken,
+		pq.Array(&user.Scopes), &user.ProjectToken, &email)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Access token lookup failed: %v", err)
+		}
+		return nil, ""
+	}
+
+	return user, email
+}
+
+// AccessTokenAllows applies the scopes and project restriction of access
+// tokens on top of the user's role permissions. Sessions are not restricted.
+func AccessTokenAllows(user *models.AuthenticatedUser, projectToken string, resource string, action string) bool {
+	if user.AccessTokenID == 0 {
+		return true
+	}
+	if user.ProjectToken != "" && user.ProjectToken != projectToken {
+		return false
+	}
+	return ScopesAllow(user.Scopes, resource, action)
+}
@@ -0,0 +1,153 @@
+package lib
+
+import (
+	"database/sql"
+
+	"project-manager-server/models"
+)
+
+// Roles with hardcoded rules on top of their role_permissions
+const (
+	ownerRoleName = "PROJECT_OWNER"
+	guestRoleName = "GUEST"
+)
+
+// GetEffectivePermissions lists what the user is allowed to do on the
+// project and where each grant comes from. CheckUserPermissions evaluates
+// the same rules, so the list always matches the actual checks.
+func GetEffectivePermissions(db *sql.DB, userPrivateToken string, projectToken string) (models.EffectivePermissions, error) {
+	effective := models.EffectivePermissions{
+		ProjectToken: projectToken,
+		Grants:       []models.PermissionGrant{},
+		Withheld:     []models.PermissionGrant{},
+	}
+
+	// The highest role counts when a member holds several
+	const roleQuery = `
+		SELECT r.id, r.name, r.display_name, r.project_token IS NULL
+		FROM projects_team_members ptm
+		JOIN roles r ON r.id = ptm.role_id
+		WHERE ptm.userprivatetoken = $1
+		AND ptm.projecttoken = $2
+		AND ptm.is_active = true
+		AND (r.project_token IS NULL OR r.project_token = ptm.projecttoken)
+		ORDER BY r.level DESC
+		LIMIT 1;
+	`
+
+	var roleID int
+	var isGlobalRole bool
+	err := db.QueryRow(roleQuery, userPrivateToken, projectToken).Scan(&roleID, &effective.Role, &effective.RoleDisplayName, &isGlobalRole)
+	if err == sql.ErrNoRows {
+		return effective, nil
+	}
+	if err != nil {
+		return effective, err
+	}
+	effective.IsMember = true
+
+	if isGlobalRole && effective.Role == ownerRoleName {
+		effective.IsOwner = true
+		grants, err := queryGrants(db, `
+			SELECT res.name, a.name, p.name, $1::text, $2::text
+			FROM permissions p
+			JOIN resources res ON res.id = p.resource_id
+			JOIN actions a ON a.id = p.action_id
+			ORDER BY res.name, a.name;
+		`, models.GrantSourceOwner, effective.Role)
+		if err != nil {
+			return effective, err
+		}
+		effective.Grants = grants
+		return effective, nil
+	}
+
+	// Inheritance is followed transitively, the path guards against cycles
+	// written before the cycle check existed. Direct grants are listed first,
+	// then the nearest ancestor's, so duplicates keep the closest source.
+	grants, err := queryGrants(db, `
+		WITH RECURSIVE ancestors (role_id, depth, path) AS (
+			SELECT ri.parent_role_id, 1, ARRAY[$1::int, ri.parent_role_id]
+			FROM role_inheritance ri
+			WHERE ri.child_role_id = $1
+			UNION ALL
+			SELECT ri.parent_role_id, a.depth + 1, a.path || ri.parent_role_id
+			FROM ancestors a
+			JOIN role_inheritance ri ON ri.child_role_id = a.role_id
+			WHERE NOT ri.parent_role_id = ANY(a.path)
+		),
+		role_sources (role_id, source, depth) AS (
+			SELECT $1::int, $2::text, 0
+			UNION ALL
+			SELECT role_id, $3::text, MIN(depth)
+			FROM ancestors
+			GROUP BY role_id
+		)
+		SELECT res.name, a.name, p.name, rs.source, r.name
+		FROM role_sources rs
+		JOIN roles r ON r.id = rs.role_id
+		JOIN role_permissions rp ON rp.role_id = rs.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		JOIN resources res ON res.id = p.resource_id
+		JOIN actions a ON a.id = p.action_id
+		ORDER BY res.name, a.name, rs.depth;
+	`, roleID, models.GrantSourceRole, models.GrantSourceInherited)
+	if err != nil {
+		return effective, err
+	}
+
+	seen := make(map[[2]string]bool)
+	for _, grant := range grants {
+		pair := [2]string{grant.Resource, grant.Action}
+		if seen[pair] {
+			continue
+		}
+		seen[pair] = true
+
+		if isGlobalRole && effective.Role == guestRoleName && grant.Action != "read" {
+			grant.Source = models.GrantSourceGuest
+			effective.Withheld = append(effective.Withheld, grant)
+			continue
+		}
+		effective.Grants = append(effective.Grants, grant)
+	}
+
+	return effective, nil
+}
+
+func queryGrants(db *sql.DB, query string, args ...any) ([]models.PermissionGrant, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.PermissionGrant{}
+	for rows.Next() {
+		var grant models.PermissionGrant
+		if err := rows.Scan(&grant.Resource, &grant.Action, &grant.Permission, &grant.Source, &grant.Role); err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
+
+// effectiveAllows applies the same shortcuts as the listing: owners may do
+// anything, even on pairs without a permissions row
+func effectiveAllows(effective models.EffectivePermissions, resource string, action string) bool {
+	if !effective.IsMember {
+		return false
+	}
+	if effective.IsOwner {
+		return true
+	}
+
+	for _, grant := range effective.Grants {
+		if grant.Resource == resource && grant.Action == action {
+			return true
+		}
+	}
+	return false
+}
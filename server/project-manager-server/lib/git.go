@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrInvalidBranch  = errors.New("invalid branch name")
+	ErrBranchNotFound = errors.New("branch not found")
+
+	ErrRepositoryNotFound = errors.New("repository not found")
+)
+
+// OpenRepository returns the bare repository of a project
+func OpenRepository(projectToken string) (string, error) {
+	gitDir, err := RepositoryPath(os.Getenv("REPOSITORIES_FOLDER_PATH"), projectToken)
+	if err != nil {
+		return "", err
+	}
+
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return "", ErrRepositoryNotFound
+	}
+
+	return gitDir, nil
+}
+
+// runGit runs git against a bare repository and returns its trimmed output.
+// Errors carry what git printed on stderr.
+func runGit(gitDir string, env []string, stdin io.Reader, args ...string) (string, error) {
+	output, err := runGitOutput(gitDir, env, stdin, args...)
+	return strings.TrimSpace(output), err
+}
+
+// runGitOutput is runGit for output whose surrounding whitespace matters,
+// such as file contents
+func runGitOutput(gitDir string, env []string, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"--git-dir=" + gitDir}, args...)...)
+	cmd.Env = append(cmd.Environ(), env...)
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// runGitLimited is runGit for commands with potentially huge output. It
+// stops git once more than limit bytes were read and reports the output as
+// truncated.
+func runGitLimited(gitDir string, limit int, args ...string) (string, bool, error) {
+	cmd := exec.Command("git", append([]string{"--git-dir=" + gitDir}, args...)...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", false, err
+	}
+
+	output, err := io.ReadAll(io.LimitReader(stdout, int64(limit)+1))
+	truncated := len(output) > limit
+	if truncated {
+		output = output[:limit]
+		cmd.Process.Kill()
+	}
+
+	waitErr := cmd.Wait()
+	if err != nil {
+		return "", false, err
+	}
+	if waitErr != nil && !truncated {
+		return "", false, fmt.Errorf("git %s: %w: %s", args[0], waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return string(output), truncated, nil
+}
+
+// ValidateBranchName checks name is usable as refs/heads/<name>
+func ValidateBranchName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return ErrInvalidBranch
+	}
+	if err := exec.Command("git", "check-ref-format", "refs/heads/"+name).Run(); err != nil {
+		return ErrInvalidBranch
+	}
+	return nil
+}
+
+// DefaultBranch returns the branch HEAD of the repository points to
+func DefaultBranch(gitDir string) (string, error) {
+	return runGit(gitDir, nil, nil, "symbolic-ref", "--short", "HEAD")
+}
+
+// resolveBranch validates branch, defaulting to the repository's default
+// branch, and returns it with its head commit. The head is empty for a
+// branch without commits.
+func resolveBranch(gitDir string, branch string) (string, string, error) {
+	if branch == "" {
+		defaultBranch, err := DefaultBranch(gitDir)
+		if err != nil {
+			return "", "", err
+		}
+		branch = defaultBranch
+	}
+
+	if err := ValidateBranchName(branch); err != nil {
+		return "", "", err
+	}
+
+	head, err := BranchHead(gitDir, branch)
+	if err != nil {
+		return "", "", err
+	}
+
+	return branch, head, nil
+}
+
+// BranchHead returns the commit branch points to, or an empty string for a
+// branch without commits
+func BranchHead(gitDir string, branch string) (string, error) {
+	cmd := exec.Command("git", "--git-dir="+gitDir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch+"^{commit}")
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("git rev-parse: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,181 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-manager-server/models"
+)
+
+var ErrInvalidLineRange = errors.New("invalid line range")
+
+// GetBlame returns who last changed each line of path at ref, grouped into
+// ranges. startLine and endLine are 1-based and inclusive, 0 means the first
+// or last line of the file.
+func GetBlame(gitDir string, ref string, path string, startLine int, endLine int) (*models.Blame, error) {
+	path, err := CleanRelativePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := ResolveCommit(gitDir, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if objectType, err := runGit(gitDir, nil, nil, "cat-file", "-t", commit+":"+path); err != nil || objectType != "blob" {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, path)
+	}
+	content, err := runGitOutput(gitDir, nil, nil, "cat-file", "blob", commit+":"+path)
+	if err != nil {
+		return nil, err
+	}
+
+	blame := &models.Blame{Commit: commit, Path: path, TotalLines: countLines(content), Ranges: []models.BlameRange{}}
+	if blame.TotalLines == 0 {
+		return blame, nil
+	}
+
+	if startLine == 0 {
+		startLine = 1
+	}
+	if endLine == 0 {
+		endLine = blame.TotalLines
+	}
+	if startLine < 1 || endLine < startLine || endLine > blame.TotalLines {
+		return nil, fmt.Errorf("%w: the file has %d lines", ErrInvalidLineRange, blame.TotalLines)
+	}
+
+	output, err := runGitOutput(gitDir, nil, nil, "blame", "--porcelain",
+		"-L", fmt.Sprintf("%d,%d", startLine, endLine), commit, "--", path)
+	if err != nil {
+		return nil, err
+	}
+
+	blame.Ranges, err = parseBlame(output)
+	return blame, err
+}
+
+// countLines counts lines the way git blame does, a last line without a
+// newline is still a line
+func countLines(content string) int {
+	lines := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	return lines
+}
+
+// blameCommit holds the commit headers of git blame --porcelain, which are
+// only printed the first time a commit appears
+type blameCommit struct {
+	author  models.CommitSignature
+	summary string
+	path    string
+}
+
+func parseBlame(output string) ([]models.BlameRange, error) {
+	ranges := []models.BlameRange{}
+	commits := make(map[string]*blameCommit)
+
+	var current *blameCommit
+	var sha string
+	var finalLine int
+	var authorTime int64
+	var authorZone string
+
+	for _, line := range strings.Split(output, "\n") {
+		if content, found := strings.CutPrefix(line, "\t"); found {
+			if current == nil {
+				return nil, fmt.Errorf("unexpected git blame line %q", line)
+			}
+			if authorTime != 0 {
+				current.author.Date = blameTime(authorTime, authorZone)
+				authorTime = 0
+			}
+
+			last := len(ranges) - 1
+			if last >= 0 && ranges[last].Commit == sha && ranges[last].EndLine == finalLine-1 {
+				ranges[last].EndLine = finalLine
+				ranges[last].Lines = append(ranges[last].Lines, content)
+			} else {
+				ranges = append(ranges, models.BlameRange{
+					Commit:    sha,
+					StartLine: finalLine,
+					EndLine:   finalLine,
+					Path:      current.path,
+					Author:    current.author,
+					Summary:   current.summary,
+					Lines:     []string{content},
+				})
+			}
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		if isHexHash(key) {
+			// <sha> <original line> <final line> [<lines in group>]
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("unexpected git blame header %q", line)
+			}
+			number, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git blame header %q", line)
+			}
+
+			sha, finalLine = key, number
+			if current = commits[sha]; current == nil {
+				current = &blameCommit{}
+				commits[sha] = current
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch key {
+		case "author":
+			current.author.Name = value
+		case "author-mail":
+			current.author.Email = strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+		case "author-time":
+			authorTime, _ = strconv.ParseInt(value, 10, 64)
+		case "author-tz":
+			authorZone = value
+		case "summary":
+			current.summary = value
+		case "filename":
+			current.path = value
+		}
+	}
+
+	return ranges, nil
+}
+
+// blameTime combines a unix time with a +hhmm zone
+func blameTime(unix int64, zone string) time.Time {
+	date := time.Unix(unix, 0).UTC()
+	if len(zone) != 5 {
+		return date
+	}
+
+	hours, err := strconv.Atoi(zone[1:3])
+	if err != nil {
+		return date
+	}
+	minutes, err := strconv.Atoi(zone[3:])
+	if err != nil {
+		return date
+	}
+
+	offset := hours*3600 + minutes*60
+	if zone[0] == '-' {
+		offset = -offset
+	}
+	return date.In(time.FixedZone(zone, offset))
+}
@@ -0,0 +1,206 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-manager-server/models"
+)
+
+var (
+	ErrBranchExists  = errors.New("a branch with this name already exists")
+	ErrDefaultBranch = errors.New("the default branch cannot be deleted")
+	ErrRefNotFound   = errors.New("ref not found")
+)
+
+// ListBranches returns the branches of the repository, the default branch
+// first and the others by most recent commit
+func ListBranches(gitDir string) ([]models.Branch, string, error) {
+	defaultBranch, err := DefaultBranch(gitDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	output, err := runGit(gitDir, nil, nil, "for-each-ref", "--sort=-committerdate",
+		"--format=%(refname:short)%00%(objectname)%00%(committerdate:unix)%00%(contents:subject)", "refs/heads")
+	if err != nil {
+		return nil, "", err
+	}
+
+	defaultHead, err := BranchHead(gitDir, defaultBranch)
+	if err != nil {
+		return nil, "", err
+	}
+
+	branches := []models.Branch{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			continue
+		}
+
+		branch := models.Branch{
+			Name:      fields[0],
+			Commit:    fields[1],
+			Subject:   fields[3],
+			IsDefault: fields[0] == defaultBranch,
+		}
+		if seconds, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			branch.UpdatedAt = time.Unix(seconds, 0).UTC()
+		}
+
+		if !branch.IsDefault && defaultHead != "" {
+			branch.Ahead, branch.Behind, err = aheadBehind(gitDir, defaultHead, branch.Commit)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+
+		if branch.IsDefault {
+			branches = append([]models.Branch{branch}, branches...)
+		} else {
+			branches = append(branches, branch)
+		}
+	}
+
+	return branches, defaultBranch, nil
+}
+
+// aheadBehind counts the commits of commit missing from base, and those of
+// base missing from commit
+func aheadBehind(gitDir string, base string, commit string) (int, int, error) {
+	output, err := runGit(gitDir, nil, nil, "rev-list", "--left-right", "--count", base+"..."+commit)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var behind, ahead int
+	if _, err := fmt.Sscanf(output, "%d %d", &behind, &ahead); err != nil {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q", output)
+	}
+	return ahead, behind, nil
+}
+
+// ResolveCommit returns the commit a branch, tag or commit hash points to
+func ResolveCommit(gitDir string, ref string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, "\x00\n") {
+		return "", ErrRefNotFound
+	}
+
+	commit, err := runGit(gitDir, nil, nil, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", ErrRefNotFound
+	}
+	return commit, nil
+}
+
+// CreateBranch creates branch at the commit from points to, the head of the
+// default branch when from is empty, and returns that commit
+func CreateBranch(gitDir string, branch string, from string) (string, error) {
+	if err := ValidateBranchName(branch); err != nil {
+		return "", err
+	}
+
+	if from == "" {
+		defaultBranch, err := DefaultBranch(gitDir)
+		if err != nil {
+			return "", err
+		}
+		from = "refs/heads/" + defaultBranch
+	}
+
+	commit, err := ResolveCommit(gitDir, from)
+	if err != nil {
+		return "", err
+	}
+
+	zero := strings.Repeat("0", len(commit))
+	if _, err := runGit(gitDir, nil, nil, "update-ref", "-m", "branch: Created from "+from, "refs/heads/"+branch, commit, zero); err != nil {
+		if head, headErr := BranchHead(gitDir, branch); headErr == nil && head != "" {
+			return "", ErrBranchExists
+		}
+		return "", err
+	}
+
+	return commit, nil
+}
+
+// RenameBranch renames a branch, HEAD follows when it is the default branch
+func RenameBranch(gitDir string, branch string, newName string) error {
+	if err := ValidateBranchName(branch); err != nil {
+		return err
+	}
+	if err := ValidateBranchName(newName); err != nil {
+		return err
+	}
+
+	if err := requireBranch(gitDir, branch); err != nil {
+		return err
+	}
+	if head, err := BranchHead(gitDir, newName); err != nil {
+		return err
+	} else if head != "" {
+		return ErrBranchExists
+	}
+
+	_, err := runGit(gitDir, nil, nil, "branch", "-m", "--", branch, newName)
+	return err
+}
+
+// DeleteBranch deletes any branch but the default one
+func DeleteBranch(gitDir string, branch string) error {
+	if err := ValidateBranchName(branch); err != nil {
+		return err
+	}
+
+	defaultBranch, err := DefaultBranch(gitDir)
+	if err != nil {
+		return err
+	}
+	if branch == defaultBranch {
+		return ErrDefaultBranch
+	}
+
+	head, err := BranchHead(gitDir, branch)
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return ErrBranchNotFound
+	}
+
+	if _, err := runGit(gitDir, nil, nil, "update-ref", "-d", "refs/heads/"+branch, head); err != nil {
+		if current, headErr := BranchHead(gitDir, branch); headErr == nil && current != head {
+			return ErrBranchMoved
+		}
+		return err
+	}
+	return nil
+}
+
+// SetDefaultBranch points HEAD of the repository to branch
+func SetDefaultBranch(gitDir string, branch string) error {
+	if err := ValidateBranchName(branch); err != nil {
+		return err
+	}
+	if err := requireBranch(gitDir, branch); err != nil {
+		return err
+	}
+
+	_, err := runGit(gitDir, nil, nil, "symbolic-ref", "-m", "default branch: "+branch, "HEAD", "refs/heads/"+branch)
+	return err
+}
+
+func requireBranch(gitDir string, branch string) error {
+	head, err := BranchHead(gitDir, branch)
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return ErrBranchNotFound
+	}
+	return nil
+}
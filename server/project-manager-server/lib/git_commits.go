@@ -0,0 +1,337 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"project-manager-server/models"
+)
+
+var (
+	ErrBranchMoved     = errors.New("the branch was updated by another commit")
+	ErrFileExists      = errors.New("a file or folder already exists at this path")
+	ErrFileNotFound    = errors.New("no file or folder exists at this path")
+	ErrPathConflict    = errors.New("the path conflicts with an existing file or folder")
+	ErrNoChanges       = errors.New("nothing to commit")
+	ErrInvalidChange   = errors.New("invalid file change")
+	ErrEmptyCommitInfo = errors.New("commit author name and email are required")
+)
+
+// Git has no empty folders, new folders get a placeholder file
+const FolderPlaceholder = ".gitkeep"
+
+type treeEntry struct {
+	mode string
+	hash string
+}
+
+// CommitFileChanges commits changes on top of the head of branch, the
+// repository's default branch when empty, and moves the branch to the new
+// commit. The work happens in a temporary index so the repository can stay
+// bare. ErrBranchMoved is returned when the branch moved while committing.
+func CommitFileChanges(gitDir string, branch string, changes []models.FileChange, author models.CommitAuthor, message string) (*models.CommitResult, error) {
+	return commitFileChanges(gitDir, branch, nil, changes, author, message)
+}
+
+// CommitFileChangesOnto is CommitFileChanges for clients that saw the branch
+// at expectedParent, empty for a branch without commits. ErrBranchMoved is
+// returned when the branch head is any other commit.
+func CommitFileChangesOnto(gitDir string, branch string, expectedParent string, changes []models.FileChange, author models.CommitAuthor, message string) (*models.CommitResult, error) {
+	return commitFileChanges(gitDir, branch, &expectedParent, changes, author, message)
+}
+
+func commitFileChanges(gitDir string, branch string, expectedParent *string, changes []models.FileChange, author models.CommitAuthor, message string) (*models.CommitResult, error) {
+	if author.Name == "" || author.Email == "" {
+		return nil, ErrEmptyCommitInfo
+	}
+	if len(changes) == 0 {
+		return nil, ErrNoChanges
+	}
+
+	branch, head, err := resolveBranch(gitDir, branch)
+	if err != nil {
+		return nil, err
+	}
+	if head == "" {
+		// Only the default branch may be unborn, committing to it creates it
+		defaultBranch, err := DefaultBranch(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		if branch != defaultBranch {
+			return nil, ErrBranchNotFound
+		}
+	}
+
+	if expectedParent != nil && !sameCommit(head, *expectedParent) {
+		return nil, ErrBranchMoved
+	}
+
+	entries, err := readTree(gitDir, head)
+	if err != nil {
+		return nil, err
+	}
+
+	indexInfo, err := applyChanges(gitDir, entries, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	indexDir, err := os.MkdirTemp("", "pm-index-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(indexDir)
+
+	env := []string{
+		"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index"),
+		"GIT_AUTHOR_NAME=" + author.Name,
+		"GIT_AUTHOR_EMAIL=" + author.Email,
+		"GIT_COMMITTER_NAME=" + author.Name,
+		"GIT_COMMITTER_EMAIL=" + author.Email,
+	}
+
+	if head != "" {
+		if _, err := runGit(gitDir, env, nil, "read-tree", head); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := runGit(gitDir, env, bytes.NewReader(indexInfo), "update-index", "-z", "--index-info"); err != nil {
+		return nil, err
+	}
+
+	tree, err := runGit(gitDir, env, nil, "write-tree")
+	if err != nil {
+		return nil, err
+	}
+
+	commitArgs := []string{"commit-tree", tree}
+	if head != "" {
+		parentTree, err := runGit(gitDir, nil, nil, "rev-parse", head+"^{tree}")
+		if err != nil {
+			return nil, err
+		}
+		if parentTree == tree {
+			return nil, ErrNoChanges
+		}
+		commitArgs = append(commitArgs, "-p", head)
+	}
+	commitArgs = append(commitArgs, "-F", "-")
+
+	commit, err := runGit(gitDir, env, strings.NewReader(message), commitArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Git treats an all-zero old value as "the ref must not exist yet"
+	oldValue := head
+	if oldValue == "" {
+		oldValue = strings.Repeat("0", len(commit))
+	}
+
+	if _, err := runGit(gitDir, env, nil, "update-ref", "-m", "commit: "+firstLine(message), "refs/heads/"+branch, commit, oldValue); err != nil {
+		if current, headErr := BranchHead(gitDir, branch); headErr == nil && current != head {
+			return nil, ErrBranchMoved
+		}
+		return nil, err
+	}
+
+	return &models.CommitResult{Commit: commit, Parent: head, Branch: branch}, nil
+}
+
+// readTree lists every file of the tree of commit, which may be empty
+func readTree(gitDir string, commit string) (map[string]treeEntry, error) {
+	entries := make(map[string]treeEntry)
+	if commit == "" {
+		return entries, nil
+	}
+
+	output, err := runGit(gitDir, nil, nil, "ls-tree", "-r", "-z", "--full-tree", commit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(output, "\x00") {
+		// Format: <mode> SP <type> SP <object> TAB <file>
+		meta, path, found := strings.Cut(line, "\t")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 3 {
+			continue
+		}
+		entries[path] = treeEntry{mode: fields[0], hash: fields[2]}
+	}
+
+	return entries, nil
+}
+
+// applyChanges validates changes against the files of the parent commit,
+// updating entries as it goes, and returns them as input for
+// git update-index -z --index-info
+func applyChanges(gitDir string, entries map[string]treeEntry, changes []models.FileChange) ([]byte, error) {
+	var indexInfo bytes.Buffer
+
+	for _, change := range changes {
+		path, err := CleanRelativePath(change.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, change.Path)
+		}
+		if hasGitDirSegment(path) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPath, change.Path)
+		}
+
+		switch change.Action {
+		case models.FileChangeRename:
+			if err := renamePath(entries, change.PreviousPath, path, &indexInfo); err != nil {
+				return nil, err
+			}
+
+		case models.FileChangeDelete:
+			removed := filesUnder(entries, path)
+			if len(removed) == 0 {
+				return nil, fmt.Errorf("%w: %s", ErrFileNotFound, path)
+			}
+			for _, file := range removed {
+				// Mode 0 removes the entry whatever object it names
+				fmt.Fprintf(&indexInfo, "0 %s\t%s\x00", entries[file].hash, file)
+				delete(entries, file)
+			}
+
+		case models.FileChangeCreate, models.FileChangeUpdate:
+			existing, exists := entries[path]
+			if change.Action == models.FileChangeCreate && (exists || len(filesUnder(entries, path)) > 0) {
+				return nil, fmt.Errorf("%w: %s", ErrFileExists, path)
+			}
+			if !exists && len(filesUnder(entries, path)) > 0 || fileAncestor(entries, path) != "" {
+				return nil, fmt.Errorf("%w: %s", ErrPathConflict, path)
+			}
+
+			mode := "100644"
+			if exists {
+				switch existing.mode {
+				case "100755":
+					mode = existing.mode
+				case "160000":
+					return nil, fmt.Errorf("%w: %s is a submodule", ErrPathConflict, path)
+				}
+			}
+
+			hash, err := runGit(gitDir, nil, strings.NewReader(change.Content), "hash-object", "-w", "--stdin")
+			if err != nil {
+				return nil, err
+			}
+
+			entries[path] = treeEntry{mode: mode, hash: hash}
+			fmt.Fprintf(&indexInfo, "%s %s\t%s\x00", mode, hash, path)
+
+		default:
+			return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidChange, change.Action)
+		}
+	}
+
+	return indexInfo.Bytes(), nil
+}
+
+// renamePath moves the file or folder at from to the free path to
+func renamePath(entries map[string]treeEntry, from string, to string, indexInfo *bytes.Buffer) error {
+	cleaned, err := CleanRelativePath(from)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, from)
+	}
+	if hasGitDirSegment(cleaned) {
+		return fmt.Errorf("%w: %s", ErrInvalidPath, from)
+	}
+	from = cleaned
+
+	if to == from || strings.HasPrefix(to, from+"/") {
+		return fmt.Errorf("%w: cannot move %s into itself", ErrInvalidChange, from)
+	}
+
+	moved := filesUnder(entries, from)
+	if len(moved) == 0 {
+		return fmt.Errorf("%w: %s", ErrFileNotFound, from)
+	}
+
+	removed := make(map[string]treeEntry, len(moved))
+	for _, file := range moved {
+		removed[file] = entries[file]
+		fmt.Fprintf(indexInfo, "0 %s\t%s\x00", entries[file].hash, file)
+		delete(entries, file)
+	}
+
+	if len(filesUnder(entries, to)) > 0 {
+		return fmt.Errorf("%w: %s", ErrFileExists, to)
+	}
+	if fileAncestor(entries, to) != "" {
+		return fmt.Errorf("%w: %s", ErrPathConflict, to)
+	}
+
+	for file, entry := range removed {
+		target := to + strings.TrimPrefix(file, from)
+		entries[target] = entry
+		fmt.Fprintf(indexInfo, "%s %s\t%s\x00", entry.mode, entry.hash, target)
+	}
+
+	return nil
+}
+
+// sameCommit compares a full commit hash with one that may be abbreviated
+func sameCommit(head string, expected string) bool {
+	if head == "" || expected == "" {
+		return head == expected
+	}
+	return len(expected) >= 7 && strings.HasPrefix(head, strings.ToLower(expected))
+}
+
+// filesUnder returns path itself when it is a file, or every file inside it
+// when it is a folder
+func filesUnder(entries map[string]treeEntry, path string) []string {
+	if _, exists := entries[path]; exists {
+		return []string{path}
+	}
+
+	var files []string
+	for file := range entries {
+		if strings.HasPrefix(file, path+"/") {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// fileAncestor returns the first parent folder of path that is a file
+func fileAncestor(entries map[string]treeEntry, path string) string {
+	for dir := parentDir(path); dir != ""; dir = parentDir(dir) {
+		if _, exists := entries[dir]; exists {
+			return dir
+		}
+	}
+	return ""
+}
+
+func parentDir(path string) string {
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func hasGitDirSegment(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if strings.EqualFold(segment, ".git") {
+			return true
+		}
+	}
+	return false
+}
+
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return line
+}
@@ -0,0 +1,327 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"project-manager-server/models"
+)
+
+var ErrInvalidDiffOptions = errors.New("invalid diff options")
+
+const (
+	DefaultDiffContextLines = 3
+	MaxDiffContextLines     = 100
+	DefaultDiffMaxFiles     = 300
+	MaxDiffMaxFiles         = 1000
+	DefaultDiffMaxBytes     = 1 << 20
+	MaxDiffMaxBytes         = 10 << 20
+)
+
+// DiffRefs compares the trees of two refs
+func DiffRefs(gitDir string, from string, to string, options models.DiffOptions) (*models.Diff, error) {
+	fromCommit, err := ResolveCommit(gitDir, from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, from)
+	}
+	toCommit, err := ResolveCommit(gitDir, to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, to)
+	}
+
+	return diffTrees(gitDir, fromCommit, toCommit, options)
+}
+
+// DiffCommit compares a commit with its first parent, or with an empty tree
+// for the first commit
+func DiffCommit(gitDir string, ref string, options models.DiffOptions) (*models.Diff, error) {
+	commit, err := ResolveCommit(gitDir, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := runGit(gitDir, nil, nil, "rev-parse", "--verify", "--quiet", commit+"^1")
+	if err != nil {
+		from, err = runGit(gitDir, nil, strings.NewReader(""), "hash-object", "-t", "tree", "--stdin")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return diffTrees(gitDir, from, commit, options)
+}
+
+func diffTrees(gitDir string, from string, to string, options models.DiffOptions) (*models.Diff, error) {
+	options, err := normalizeDiffOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The file list and stats come from the raw diff, which ignores the
+	// whitespace options, so files with whitespace changes only are listed
+	// without hunks
+	nameStatus, err := runGit(gitDir, nil, nil, "diff", "--no-ext-diff", "-z", "-M", "--name-status", from, to)
+	if err != nil {
+		return nil, err
+	}
+	changed := parseNameStatus(nameStatus)
+
+	diff := &models.Diff{From: from, To: to, TotalFiles: len(changed), Files: []models.DiffFile{}}
+
+	args := []string{"diff", "--no-ext-diff", "--no-color", "-M", "--src-prefix=a/", "--dst-prefix=b/",
+		"--unified=" + strconv.Itoa(options.ContextLines)}
+	switch options.IgnoreWhitespace {
+	case models.IgnoreWhitespaceAll:
+		args = append(args, "--ignore-all-space")
+	case models.IgnoreWhitespaceChange:
+		args = append(args, "--ignore-space-change")
+	case models.IgnoreWhitespaceEOL:
+		args = append(args, "--ignore-space-at-eol")
+	}
+	args = append(args, from, to)
+
+	// Past the file limit, both diffs are restricted to the files kept
+	var pathspec []string
+	if len(changed) > options.MaxFiles {
+		changed = changed[:options.MaxFiles]
+		diff.Truncated = true
+
+		pathspec = append(pathspec, "--")
+		for _, file := range changed {
+			pathspec = append(pathspec, ":(literal)"+file.Path)
+			if file.PreviousPath != "" {
+				pathspec = append(pathspec, ":(literal)"+file.PreviousPath)
+			}
+		}
+		args = append(args, pathspec...)
+	}
+
+	numstat, err := runGit(gitDir, nil, nil, append([]string{"diff", "--no-ext-diff", "-z", "-M", "--numstat", from, to}, pathspec...)...)
+	if err != nil {
+		return nil, err
+	}
+	stats := parseNumstat(numstat)
+
+	patch, truncated, err := runGitLimited(gitDir, options.MaxBytes, args...)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		diff.Truncated = true
+		// Only keep complete file patches
+		if end := strings.LastIndex(patch, "\ndiff --git "); end >= 0 {
+			patch = patch[:end+1]
+		} else {
+			patch = ""
+		}
+	}
+	diff.Patch = patch
+
+	hunks := parsePatch(patch)
+	for _, file := range changed {
+		if stat, found := stats[file.Path]; found {
+			file.Additions, file.Deletions, file.Binary = stat.additions, stat.deletions, stat.binary
+		}
+
+		diffFile := models.DiffFile{ChangedFile: file, Hunks: []models.DiffHunk{}}
+		if fileHunks, found := hunks[file.Path]; found {
+			diffFile.Hunks = fileHunks
+		} else if truncated && !file.Binary {
+			diffFile.Truncated = true
+		}
+		diff.Files = append(diff.Files, diffFile)
+	}
+
+	return diff, nil
+}
+
+func normalizeDiffOptions(options models.DiffOptions) (models.DiffOptions, error) {
+	switch options.IgnoreWhitespace {
+	case models.IgnoreWhitespaceNone, models.IgnoreWhitespaceAll, models.IgnoreWhitespaceChange, models.IgnoreWhitespaceEOL:
+	default:
+		return options, fmt.Errorf("%w: ignoreWhitespace must be %q, %q or %q", ErrInvalidDiffOptions,
+			models.IgnoreWhitespaceAll, models.IgnoreWhitespaceChange, models.IgnoreWhitespaceEOL)
+	}
+
+	if options.ContextLines < 0 || options.ContextLines > MaxDiffContextLines {
+		return options, fmt.Errorf("%w: context must be between 0 and %d", ErrInvalidDiffOptions, MaxDiffContextLines)
+	}
+
+	if options.MaxFiles == 0 {
+		options.MaxFiles = DefaultDiffMaxFiles
+	}
+	if options.MaxFiles < 1 || options.MaxFiles > MaxDiffMaxFiles {
+		return options, fmt.Errorf("%w: maxFiles must be between 1 and %d", ErrInvalidDiffOptions, MaxDiffMaxFiles)
+	}
+
+	if options.MaxBytes == 0 {
+		options.MaxBytes = DefaultDiffMaxBytes
+	}
+	if options.MaxBytes < 1 || options.MaxBytes > MaxDiffMaxBytes {
+		return options, fmt.Errorf("%w: maxBytes must be between 1 and %d", ErrInvalidDiffOptions, MaxDiffMaxBytes)
+	}
+
+	return options, nil
+}
+
+// parsePatch splits a unified diff into the hunks of every file, keyed by
+// the new path, or the old one for deleted files
+func parsePatch(patch string) map[string][]models.DiffHunk {
+	files := make(map[string][]models.DiffHunk)
+
+	var header []string
+	var hunks []models.DiffHunk
+	var oldLine, newLine int
+	inHunk := false
+
+	flush := func() {
+		if header != nil {
+			if path := patchPath(header); path != "" {
+				files[path] = hunks
+			}
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			header = []string{line}
+			hunks = []models.DiffHunk{}
+			inHunk = false
+			continue
+		}
+		if header == nil {
+			continue
+		}
+
+		if strings.HasPrefix(line, "@@ ") {
+			hunk, ok := parseHunkHeader(line)
+			if !ok {
+				continue
+			}
+			hunks = append(hunks, hunk)
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+			inHunk = true
+			continue
+		}
+
+		if !inHunk {
+			header = append(header, line)
+			continue
+		}
+
+		hunk := &hunks[len(hunks)-1]
+		switch {
+		case strings.HasPrefix(line, "+"):
+			hunk.Lines = append(hunk.Lines, models.DiffLine{Type: "addition", Content: line[1:], NewLine: newLine})
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			hunk.Lines = append(hunk.Lines, models.DiffLine{Type: "deletion", Content: line[1:], OldLine: oldLine})
+			oldLine++
+		case strings.HasPrefix(line, " "), line == "":
+			hunk.Lines = append(hunk.Lines, models.DiffLine{Type: "context", Content: strings.TrimPrefix(line, " "), OldLine: oldLine, NewLine: newLine})
+			oldLine++
+			newLine++
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" belongs to the line before
+			if len(hunk.Lines) > 0 {
+				hunk.Lines[len(hunk.Lines)-1].NoNewline = true
+			}
+		}
+	}
+	flush()
+
+	return files
+}
+
+// parseHunkHeader reads "@@ -oldStart,oldLines +newStart,newLines @@ section"
+func parseHunkHeader(line string) (models.DiffHunk, bool) {
+	hunk := models.DiffHunk{Header: line, Lines: []models.DiffLine{}}
+
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[3] != "@@" {
+		return hunk, false
+	}
+
+	var ok bool
+	if hunk.OldStart, hunk.OldLines, ok = parseHunkRange(fields[1], "-"); !ok {
+		return hunk, false
+	}
+	if hunk.NewStart, hunk.NewLines, ok = parseHunkRange(fields[2], "+"); !ok {
+		return hunk, false
+	}
+	return hunk, true
+}
+
+func parseHunkRange(value string, prefix string) (int, int, bool) {
+	value, found := strings.CutPrefix(value, prefix)
+	if !found {
+		return 0, 0, false
+	}
+
+	startValue, linesValue, hasLines := strings.Cut(value, ",")
+	start, err := strconv.Atoi(startValue)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	lines := 1
+	if hasLines {
+		if lines, err = strconv.Atoi(linesValue); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, lines, true
+}
+
+// patchPath finds the path of a file patch from its header lines
+func patchPath(header []string) string {
+	var oldPath, newPath string
+	for _, line := range header[1:] {
+		switch {
+		case strings.HasPrefix(line, "rename to "):
+			return unquotePath(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "copy to "):
+			return unquotePath(strings.TrimPrefix(line, "copy to "))
+		case strings.HasPrefix(line, "--- "):
+			oldPath = strings.TrimPrefix(unquotePath(strings.TrimPrefix(line, "--- ")), "a/")
+		case strings.HasPrefix(line, "+++ "):
+			newPath = strings.TrimPrefix(unquotePath(strings.TrimPrefix(line, "+++ ")), "b/")
+		}
+	}
+
+	if newPath != "" && newPath != "/dev/null" {
+		return newPath
+	}
+	if oldPath != "" && oldPath != "/dev/null" {
+		return oldPath
+	}
+
+	// Binary and mode changes have no ---/+++ lines. Without a rename both
+	// sides of "diff --git a/<path> b/<path>" are the same path.
+	paths := strings.TrimPrefix(header[0], "diff --git ")
+	if strings.HasPrefix(paths, `"`) {
+		if i := strings.Index(paths, `" "b/`); i >= 0 {
+			return strings.TrimPrefix(unquotePath(paths[i+2:]), "b/")
+		}
+		return ""
+	}
+	if length := len(paths) - len("a/ b/"); length > 0 && length%2 == 0 {
+		return strings.TrimPrefix(paths[len(paths)-length/2-2:], "b/")
+	}
+	return ""
+}
+
+// unquotePath undoes the C style quoting git applies to unusual paths. The
+// tab git appends to ---/+++ paths containing spaces is dropped.
+func unquotePath(path string) string {
+	path = strings.TrimSuffix(path, "\t")
+	if strings.HasPrefix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			return unquoted
+		}
+	}
+	return path
+}
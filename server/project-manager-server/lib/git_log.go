@@ -0,0 +1,301 @@
+package lib
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-manager-server/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultCommitLogLimit = 30
+	MaxCommitLogLimit     = 100
+)
+
+// Fields are separated by NUL and commits by a record separator, messages
+// span several lines
+const commitFormat = "%H%x00%P%x00%an%x00%ae%x00%aI%x00%cn%x00%ce%x00%cI%x00%B%x1e"
+
+// GetCommitLog returns one page of the history of query.Ref, the default
+// branch when empty. The first page pins the head commit into the cursor so
+// later pages stay stable while the branch moves.
+func GetCommitLog(gitDir string, query models.CommitLogQuery) (*models.CommitLogPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultCommitLogLimit
+	}
+	if limit > MaxCommitLogLimit {
+		limit = MaxCommitLogLimit
+	}
+
+	var head string
+	var offset int
+	if query.Cursor != "" {
+		var err error
+		head, offset, err = decodeCursor(gitDir, query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ref := query.Ref
+		if ref == "" {
+			defaultBranch, err := DefaultBranch(gitDir)
+			if err != nil {
+				return nil, err
+			}
+			ref = "refs/heads/" + defaultBranch
+		}
+
+		var err error
+		head, err = ResolveCommit(gitDir, ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{"log", "--format=" + commitFormat, "--skip=" + strconv.Itoa(offset), "--max-count=" + strconv.Itoa(limit+1)}
+	if query.Author != "" {
+		args = append(args, "--fixed-strings", "--regexp-ignore-case", "--author="+query.Author)
+	}
+	if !query.Since.IsZero() {
+		args = append(args, "--since="+query.Since.Format(time.RFC3339))
+	}
+	if !query.Until.IsZero() {
+		args = append(args, "--until="+query.Until.Format(time.RFC3339))
+	}
+
+	var pathspec []string
+	if query.Path != "" {
+		path, err := CleanRelativePath(query.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Renames can only be followed for a single file
+		if objectType, err := runGit(gitDir, nil, nil, "cat-file", "-t", head+":"+path); err == nil && objectType == "blob" {
+			args = append(args, "--follow")
+		}
+		pathspec = []string{"--", ":(literal)" + path}
+	}
+
+	args = append(args, head)
+	args = append(args, pathspec...)
+
+	output, err := runGit(gitDir, nil, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := parseCommits(output)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.CommitLogPage{Commits: commits}
+	if len(commits) > limit {
+		page.Commits = commits[:limit]
+		page.NextCursor = encodeCursor(head, offset+limit)
+	}
+
+	return page, nil
+}
+
+// GetCommitDetail returns a commit with the files it changed compared to its
+// first parent
+func GetCommitDetail(gitDir string, ref string) (*models.CommitDetail, error) {
+	sha, err := ResolveCommit(gitDir, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := runGit(gitDir, nil, nil, "show", "--no-patch", "--format="+commitFormat, sha)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := parseCommits(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) != 1 {
+		return nil, fmt.Errorf("unexpected git show output for %s", sha)
+	}
+
+	detail := &models.CommitDetail{Commit: commits[0]}
+
+	diffArgs := []string{"diff-tree", "-r", "-z", "-M", "--no-commit-id"}
+	if len(detail.Parents) == 0 {
+		diffArgs = append(diffArgs, "--root", sha)
+	} else {
+		diffArgs = append(diffArgs, detail.Parents[0], sha)
+	}
+
+	nameStatus, err := runGit(gitDir, nil, nil, append(diffArgs, "--name-status")...)
+	if err != nil {
+		return nil, err
+	}
+	numstat, err := runGit(gitDir, nil, nil, append(diffArgs, "--numstat")...)
+	if err != nil {
+		return nil, err
+	}
+
+	detail.Files = parseNameStatus(nameStatus)
+	stats := parseNumstat(numstat)
+	for i := range detail.Files {
+		file := &detail.Files[i]
+		if stat, found := stats[file.Path]; found {
+			file.Additions, file.Deletions, file.Binary = stat.additions, stat.deletions, stat.binary
+			detail.Additions += stat.additions
+			detail.Deletions += stat.deletions
+		}
+	}
+
+	return detail, nil
+}
+
+func parseCommits(output string) ([]models.Commit, error) {
+	commits := []models.Commit{}
+
+	for _, record := range strings.Split(output, "\x1e") {
+		record = strings.TrimPrefix(record, "\n")
+		if record == "" {
+			continue
+		}
+
+		fields := strings.SplitN(record, "\x00", 9)
+		if len(fields) != 9 {
+			return nil, fmt.Errorf("unexpected git log record %q", record)
+		}
+
+		commit := models.Commit{
+			SHA:       fields[0],
+			Parents:   strings.Fields(fields[1]),
+			Author:    models.CommitSignature{Name: fields[2], Email: fields[3]},
+			Committer: models.CommitSignature{Name: fields[5], Email: fields[6]},
+			Message:   strings.TrimRight(fields[8], "\n"),
+		}
+		commit.Author.Date, _ = time.Parse(time.RFC3339, fields[4])
+		commit.Committer.Date, _ = time.Parse(time.RFC3339, fields[7])
+
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+var fileStatuses = map[byte]string{
+	'A': "added",
+	'M': "modified",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+	'T': "type-changed",
+}
+
+// parseNameStatus reads git diff-tree -z --name-status, where renames and
+// copies are followed by both paths
+func parseNameStatus(output string) []models.ChangedFile {
+	files := []models.ChangedFile{}
+	fields := strings.Split(output, "\x00")
+
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "" {
+			continue
+		}
+
+		code := fields[i][0]
+		status, known := fileStatuses[code]
+		if !known {
+			status = "modified"
+		}
+
+		if (code == 'R' || code == 'C') && i+2 < len(fields) {
+			files = append(files, models.ChangedFile{PreviousPath: fields[i+1], Path: fields[i+2], Status: status})
+			i += 2
+		} else if i+1 < len(fields) {
+			files = append(files, models.ChangedFile{Path: fields[i+1], Status: status})
+			i++
+		}
+	}
+
+	return files
+}
+
+type fileStat struct {
+	additions int
+	deletions int
+	binary    bool
+}
+
+// parseNumstat reads git diff-tree -z --numstat, keyed by the new path.
+// Renames leave the path field empty and append the old and new paths.
+func parseNumstat(output string) map[string]fileStat {
+	stats := make(map[string]fileStat)
+	fields := strings.Split(output, "\x00")
+
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		path := parts[2]
+		if path == "" && i+2 < len(fields) {
+			path = fields[i+2]
+			i += 2
+		}
+
+		var stat fileStat
+		if parts[0] == "-" && parts[1] == "-" {
+			stat.binary = true
+		} else {
+			stat.additions, _ = strconv.Atoi(parts[0])
+			stat.deletions, _ = strconv.Atoi(parts[1])
+		}
+		stats[path] = stat
+	}
+
+	return stats
+}
+
+// Cursors are opaque to clients, they carry the pinned head and the number
+// of commits already returned
+func encodeCursor(head string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(head + ":" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(gitDir string, cursor string) (string, int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+
+	head, offsetValue, found := strings.Cut(string(decoded), ":")
+	offset, err := strconv.Atoi(offsetValue)
+	if !found || err != nil || offset < 0 || !isHexHash(head) {
+		return "", 0, ErrInvalidCursor
+	}
+
+	if _, err := ResolveCommit(gitDir, head); err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+
+	return head, offset, nil
+}
+
+func isHexHash(value string) bool {
+	if len(value) != 40 && len(value) != 64 {
+		return false
+	}
+	for _, char := range value {
+		if !strings.ContainsRune("0123456789abcdef", char) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-manager-server/models"
+)
+
+var ErrInvalidSearch = errors.New("invalid search")
+
+const (
+	defaultSearchLimit   = 50
+	maxSearchLimit       = 200
+	maxSearchContext     = 10
+	maxSearchQueryLength = 1000
+	maxSearchGlobs       = 20
+	// Longer lines, typically minified files, are cut in the results
+	maxSearchLineLength = 1000
+	searchTimeout       = 10 * time.Second
+)
+
+// SearchCode greps the files of a commit, skipping binary files. Like the
+// commit log, the cursor pins the commit the first page was searched at.
+func SearchCode(gitDir string, query models.SearchQuery) (*models.SearchPage, error) {
+	if query.Query == "" || len(query.Query) > maxSearchQueryLength || strings.ContainsAny(query.Query, "\x00\n") {
+		return nil, fmt.Errorf("%w: the query must be between 1 and %d characters on one line", ErrInvalidSearch, maxSearchQueryLength)
+	}
+	if query.ContextLines < 0 || query.ContextLines > maxSearchContext {
+		return nil, fmt.Errorf("%w: context must be between 0 and %d", ErrInvalidSearch, maxSearchContext)
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+	if query.Limit < 1 || query.Limit > maxSearchLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearch, maxSearchLimit)
+	}
+
+	args := []string{"grep", "-z", "--line-number", "--column", "-I", "--no-color", "--full-name"}
+	switch query.Mode {
+	case models.SearchLiteral, "":
+		args = append(args, "--fixed-strings")
+	case models.SearchRegex:
+		args = append(args, "--extended-regexp")
+	default:
+		return nil, fmt.Errorf("%w: mode must be %q or %q", ErrInvalidSearch, models.SearchLiteral, models.SearchRegex)
+	}
+	if query.IgnoreCase {
+		args = append(args, "--ignore-case")
+	}
+
+	pathspec, err := searchPathspec(query.Include, query.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, offset := "", 0
+	if query.Cursor != "" {
+		if commit, offset, err = decodeCursor(gitDir, query.Cursor); err != nil {
+			return nil, err
+		}
+	} else {
+		ref := query.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if commit, err = ResolveCommit(gitDir, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	args = append(args, "-e", query.Query, commit, "--")
+	args = append(args, pathspec...)
+
+	page := &models.SearchPage{Commit: commit, Matches: []models.SearchMatch{}}
+
+	// One match more than the page tells whether there is a next page
+	matches, timedOut, err := grepMatches(gitDir, commit, offset+query.Limit+1, args...)
+	if err != nil {
+		return nil, err
+	}
+	page.TimedOut = timedOut
+
+	if len(matches) > offset {
+		matches = matches[offset:]
+		if len(matches) > query.Limit {
+			matches = matches[:query.Limit]
+			page.NextCursor = encodeCursor(commit, offset+query.Limit)
+		}
+		page.Matches = matches
+	}
+
+	if query.ContextLines > 0 {
+		if err := addSearchContext(gitDir, commit, page.Matches, query.ContextLines); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// searchPathspec turns the globs into pathspecs. The glob magic also keeps
+// patterns from adding magic of their own.
+func searchPathspec(include []string, exclude []string) ([]string, error) {
+	if len(include)+len(exclude) > maxSearchGlobs {
+		return nil, fmt.Errorf("%w: at most %d path patterns are allowed", ErrInvalidSearch, maxSearchGlobs)
+	}
+
+	var pathspec []string
+	for _, globs := range []struct {
+		patterns []string
+		magic    string
+	}{{include, ":(glob)"}, {exclude, ":(glob,exclude)"}} {
+		for _, pattern := range globs.patterns {
+			pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+			if pattern == "" || strings.ContainsAny(pattern, "\x00\n") {
+				return nil, fmt.Errorf("%w: invalid path pattern", ErrInvalidSearch)
+			}
+			if !strings.Contains(pattern, "/") {
+				pattern = "**/" + pattern
+			}
+			pathspec = append(pathspec, globs.magic+pattern)
+		}
+	}
+
+	return pathspec, nil
+}
+
+// grepMatches runs git grep until it printed limit matches or searchTimeout
+// passed. Output lines look like <commit>:<path>\0<line>\0<column>\0<content>.
+func grepMatches(gitDir string, commit string, limit int, args ...string) ([]models.SearchMatch, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir=" + gitDir}, args...)...)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, false, err
+	}
+
+	matches := []models.SearchMatch{}
+	reader := bufio.NewReader(stdout)
+	for len(matches) < limit {
+		line, err := reader.ReadString('\n')
+		if line == "" && err != nil {
+			break
+		}
+
+		fields := strings.SplitN(strings.TrimSuffix(line, "\n"), "\x00", 4)
+		if len(fields) != 4 {
+			continue
+		}
+
+		match := models.SearchMatch{
+			Path:    strings.TrimPrefix(fields[0], commit+":"),
+			Content: truncateLine(fields[3]),
+			Before:  []string{},
+			After:   []string{},
+		}
+		match.Line, _ = strconv.Atoi(fields[1])
+		match.Column, _ = strconv.Atoi(fields[2])
+		matches = append(matches, match)
+	}
+
+	// Stop git once the page is full, the rest of the output is not needed
+	stopped := len(matches) >= limit
+	if stopped {
+		cmd.Process.Kill()
+	}
+	io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return matches, true, nil
+	}
+	if err != nil && !stopped {
+		var exitErr *exec.ExitError
+		// Status 1 means nothing matched
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return matches, false, nil
+		}
+		message := strings.TrimSpace(stderr.String())
+		if strings.HasPrefix(message, "fatal: -e option") {
+			return nil, false, fmt.Errorf("%w: %s", ErrInvalidSearch, strings.TrimPrefix(message, "fatal: "))
+		}
+		return nil, false, fmt.Errorf("git grep: %w: %s", err, message)
+	}
+
+	return matches, false, nil
+}
+
+// addSearchContext fills in the lines around every match from the file
+// contents
+func addSearchContext(gitDir string, commit string, matches []models.SearchMatch, contextLines int) error {
+	var lines []string
+	path := ""
+
+	for i := range matches {
+		match := &matches[i]
+		if lines == nil || match.Path != path {
+			content, err := runGitOutput(gitDir, nil, nil, "cat-file", "blob", commit+":"+match.Path)
+			if err != nil {
+				return err
+			}
+			lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+			path = match.Path
+		}
+
+		// Line numbers start at 1, lines[match.Line-1] is the match
+		for number := max(match.Line-contextLines, 1); number < match.Line; number++ {
+			match.Before = append(match.Before, truncateLine(lines[number-1]))
+		}
+		for number := match.Line + 1; number <= min(match.Line+contextLines, len(lines)); number++ {
+			match.After = append(match.After, truncateLine(lines[number-1]))
+		}
+	}
+
+	return nil
+}
+
+func truncateLine(line string) string {
+	if len(line) <= maxSearchLineLength {
+		return line
+	}
+	return strings.ToValidUTF8(line[:maxSearchLineLength], "")
+}
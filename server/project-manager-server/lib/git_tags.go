@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-manager-server/models"
+)
+
+var (
+	ErrInvalidTag  = errors.New("invalid tag name")
+	ErrTagExists   = errors.New("a tag with this name already exists")
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+// Fields are separated by NUL and tags by a record separator, annotated tag
+// messages span several lines
+const tagFormat = "%(refname:short)%00%(objecttype)%00%(objectname)%00%(*objectname)%00" +
+	"%(taggername) %(taggeremail)%00%(taggerdate:unix)%00%(contents)%1e"
+
+// ValidateTagName checks name is usable as refs/tags/<name>
+func ValidateTagName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return ErrInvalidTag
+	}
+	if err := exec.Command("git", "check-ref-format", "refs/tags/"+name).Run(); err != nil {
+		return ErrInvalidTag
+	}
+	return nil
+}
+
+// ListTags returns the tags of the repository, newest first
+func ListTags(gitDir string) ([]models.Tag, error) {
+	output, err := runGit(gitDir, nil, nil, "for-each-ref", "--sort=-creatordate", "--format="+tagFormat, "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []models.Tag{}
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.SplitN(strings.TrimPrefix(record, "\n"), "\x00", 7)
+		if len(fields) != 7 {
+			continue
+		}
+
+		tag := models.Tag{Name: fields[0], Commit: fields[2]}
+		if fields[1] == "tag" {
+			tag.Annotated = true
+			tag.Commit = fields[3]
+			tag.Message = strings.TrimSpace(fields[6])
+			tag.Tagger = strings.TrimSpace(fields[4])
+			if seconds, err := strconv.ParseInt(fields[5], 10, 64); err == nil {
+				taggedAt := time.Unix(seconds, 0).UTC()
+				tag.TaggedAt = &taggedAt
+			}
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// TagExists reports whether the repository has a tag called name
+func TagExists(gitDir string, name string) (bool, error) {
+	target, err := tagTarget(gitDir, name)
+	return target != "", err
+}
+
+// CreateTag tags the commit from points to, the head of the default branch
+// when empty. The tag is annotated by tagger when message is set and
+// lightweight otherwise.
+func CreateTag(gitDir string, name string, from string, message string, tagger models.CommitAuthor) (*models.Tag, error) {
+	if err := ValidateTagName(name); err != nil {
+		return nil, err
+	}
+
+	if from == "" {
+		defaultBranch, err := DefaultBranch(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		from = "refs/heads/" + defaultBranch
+	}
+
+	commit, err := ResolveCommit(gitDir, from)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists, err := TagExists(gitDir, name); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, ErrTagExists
+	}
+
+	tag := &models.Tag{Name: name, Commit: commit}
+
+	if strings.TrimSpace(message) == "" {
+		zero := strings.Repeat("0", len(commit))
+		_, err = runGit(gitDir, nil, nil, "update-ref", "refs/tags/"+name, commit, zero)
+	} else {
+		if tagger.Name == "" || tagger.Email == "" {
+			return nil, ErrEmptyCommitInfo
+		}
+		env := []string{"GIT_COMMITTER_NAME=" + tagger.Name, "GIT_COMMITTER_EMAIL=" + tagger.Email}
+		_, err = runGit(gitDir, env, strings.NewReader(message), "tag", "--annotate", "--file=-", name, commit)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		tag.Annotated = true
+		tag.Message = strings.TrimSpace(message)
+		tag.Tagger = tagger.Name + " <" + tagger.Email + ">"
+		tag.TaggedAt = &now
+	}
+
+	if err != nil {
+		// Someone else created the tag in the meantime
+		if exists, existsErr := TagExists(gitDir, name); existsErr == nil && exists {
+			return nil, ErrTagExists
+		}
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// DeleteTag removes a tag from the repository
+func DeleteTag(gitDir string, name string) error {
+	if err := ValidateTagName(name); err != nil {
+		return err
+	}
+
+	target, err := tagTarget(gitDir, name)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return ErrTagNotFound
+	}
+
+	_, err = runGit(gitDir, nil, nil, "update-ref", "-d", "refs/tags/"+name, target)
+	return err
+}
+
+// tagTarget returns the object refs/tags/<name> points to, or an empty string
+// when there is no such tag
+func tagTarget(gitDir string, name string) (string, error) {
+	cmd := exec.Command("git", "--git-dir="+gitDir, "rev-parse", "--verify", "--quiet", "refs/tags/"+name)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -15,29 +15,9 @@ import (
 	"project-manager-server/models"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	_ "github.com/lib/pq"
 )
 
-func CreateToken() string {
-
-	// Generate the secret extension based on the current timestamp
-	secretExt := time.Now().Unix()
-
-	// Get the secret key from environment variable and append the timestamp
-	jwtSecretKey := fmt.Sprintf("%s%d", os.Getenv("ACCOUNT_SECRET"), secretExt)
-
-	token := jwt.New(jwt.SigningMethodHS256)
-
-	// Sign the token using the secret key
-	tokenString, err := token.SignedString([]byte(jwtSecretKey))
-	if err != nil {
-		return ""
-	}
-
-	return tokenString
-}
-
 func GetPublicTokenByPrivateToken(PrivateToken string, db *sql.DB) string {
 	rows, err := db.Query("SELECT UserPublicToken FROM users WHERE UserPrivateToken=$1;", PrivateToken)
 	if err != nil {
@@ -60,20 +40,99 @@ func GetPublicTokenByPrivateToken(PrivateToken string, db *sql.DB) string {
 	return userPublicToken
 }
 
+// GetPrivateTokenByPublicToken returns an empty string for unknown users
+func GetPrivateTokenByPublicToken(publicToken string, db *sql.DB) string {
+	var userPrivateToken string
+	err := db.QueryRow("SELECT UserPrivateToken FROM users WHERE UserPublicToken=$1;", publicToken).Scan(&userPrivateToken)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] User lookup failed: %v", err)
+		}
+		return ""
+	}
+
+	return userPrivateToken
+}
+
+// GetCommitAuthor returns the name and email commits made on behalf of the
+// user are authored with
+func GetCommitAuthor(userPrivateToken string, db *sql.DB) (models.CommitAuthor, error) {
+	var author models.CommitAuthor
+	err := db.QueryRow("SELECT UserName, UserEmail FROM users WHERE UserPrivateToken=$1;", userPrivateToken).Scan(&author.Name, &author.Email)
+	if err == sql.ErrNoRows {
+		return author, ErrUserNotFound
+	}
+	return author, err
+}
+
+// Sessions older than SESSION_MAX_AGE are treated as expired
+const defaultSessionMaxAge = 30 * 24 * time.Hour
+
+func getSessionMaxAge() time.Duration {
+	if value := os.Getenv("SESSION_MAX_AGE"); value != "" {
+		if maxAge, err := time.ParseDuration(value); err == nil {
+			return maxAge
+		}
+		log.Printf("Invalid SESSION_MAX_AGE %q, using %s", value, defaultSessionMaxAge)
+	}
+	return defaultSessionMaxAge
+}
+
+// GetPrivateTokenBySessionToken returns an empty string when the session is
+// unknown or expired
 func GetPrivateTokenBySessionToken(sessionToken string, db *sql.DB) string {
+	if sessionToken == "" {
+		return ""
+	}
+
 	const query = `
 		SELECT u.UserPrivateToken
 		FROM account_sessions s
 		INNER JOIN users u ON s.userID = u.id
 		WHERE s.userSessionToken = $1
+		AND s.created_at > $2
 		LIMIT 1;
 	`
 
-	row := db.QueryRow(query, sessionToken)
+	row := db.QueryRow(query, sessionToken, time.Now().Add(-getSessionMaxAge()))
 
 	var userPrivateToken string
 	if err := row.Scan(&userPrivateToken); err != nil {
-		return "error"
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Session lookup failed: %v", err)
+		}
+		return ""
+	}
+
+	return userPrivateToken
+}
+
+// GetPrivateTokenByCredentials resolves git clients that authenticate with
+// the user's email and a session token. It returns an empty string when the
+// session is unknown, expired or belongs to another user.
+func GetPrivateTokenByCredentials(email string, sessionToken string, db *sql.DB) string {
+	if email == "" || sessionToken == "" {
+		return ""
+	}
+
+	const query = `
+		SELECT u.UserPrivateToken
+		FROM account_sessions s
+		INNER JOIN users u ON s.userID = u.id
+		WHERE s.userSessionToken = $1
+		AND s.created_at > $2
+		AND LOWER(u.UserEmail) = LOWER($3)
+		LIMIT 1;
+	`
+
+	row := db.QueryRow(query, sessionToken, time.Now().Add(-getSessionMaxAge()), email)
+
+	var userPrivateToken string
+	if err := row.Scan(&userPrivateToken); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Credentials lookup failed: %v", err)
+		}
+		return ""
 	}
 
 	return userPrivateToken
@@ -83,6 +142,16 @@ func GetPrivateTokenBySessionToken(sessionToken string, db *sql.DB) string {
 //		Projects Related		//
 //////////////////////////////////
 
+// GetRepositoryUrl returns the smart HTTP clone URL of a hosted repository
+func GetRepositoryUrl(projectToken string) string {
+	scheme := "http"
+	if os.Getenv("TLS_CERT_FILE") != "" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s:5200/api/repositories/%s.git", scheme, os.Getenv("SERVER_HOST"), projectToken)
+}
+
 func GetDirectoryStructure(basePath string) ([]models.FileNode, error) {
 	var nodes []models.FileNode
 
@@ -249,123 +318,47 @@ func CopyDir(src string, dst string) error {
 	})
 }
 func CheckPermissions(db *sql.DB, userSessionToken string, projectToken string, resource string, action string) bool {
-	log.Printf("[DEBUG] CheckPermissions called with: userSessionToken=%s, projectToken=%s, resource=%s, action=%s", 
-		userSessionToken, projectToken, resource, action)
-	
 	userPrivateToken := GetPrivateTokenBySessionToken(userSessionToken, db)
 
 	if userPrivateToken == "" {
 		return false
 	}
 
-	permissionQuery := `WITH user_roles AS (
-                    SELECT DISTINCT r.id, r.level, r.name, r.display_name
-                    FROM projects_team_members ptm
-                    JOIN roles r ON r.id = ptm.role_id
-                    WHERE ptm.userprivatetoken = $1
-                    AND ptm.projecttoken = $2
-                    AND ptm.is_active = true
-                    ORDER BY r.level DESC
-                    LIMIT 1
-                ),
-                required_permission AS (
-                    SELECT p.id as permission_id, p.name as permission_name
-                    FROM permissions p
-                    JOIN resources res ON res.id = p.resource_id
-                    JOIN actions a ON a.id = p.action_id
-                    WHERE res.name = $3
-                    AND a.name = $4
-                ),
-                user_permissions AS (
-                    SELECT DISTINCT p.id as permission_id, p.name as permission_name
-                    FROM user_roles ur
-                    JOIN role_permissions rp ON rp.role_id = ur.id
-                    JOIN permissions p ON p.id = rp.permission_id
-                    JOIN resources res ON res.id = p.resource_id
-                    JOIN actions a ON a.id = p.action_id
-                    WHERE res.name = $3
-                    AND a.name = $4
-                ),
-                role_hierarchy_permissions AS (
-                    SELECT DISTINCT p.id as permission_id, p.name as permission_name
-                    FROM user_roles ur
-                    JOIN role_inheritance ri ON ri.child_role_id = ur.id
-                    JOIN role_permissions rp ON rp.role_id = ri.parent_role_id
-                    JOIN permissions p ON p.id = rp.permission_id
-                    JOIN resources res ON res.id = p.resource_id
-                    JOIN actions a ON a.id = p.action_id
-                    WHERE res.name = $3
-                    AND a.name = $4
-                )
-                SELECT 
-                    CASE 
-                        WHEN ur.name = 'PROJECT_OWNER' THEN true
-                        WHEN ur.name = 'GUEST' AND $4 != 'read' THEN false
-                        WHEN rp.permission_id IS NOT NULL THEN true
-                        WHEN up.permission_id IS NOT NULL THEN true
-                        WHEN rhp.permission_id IS NOT NULL THEN true
-                        ELSE false
-                    END as has_permission,
-                    ur.level as user_level,
-                    ur.name as role_name,
-                    ur.display_name as role_display_name,
-                    rp.permission_name as direct_permission,
-                    up.permission_name as user_permission,
-                    rhp.permission_name as inherited_permission
-                FROM user_roles ur
-                LEFT JOIN required_permission rp ON true
-                LEFT JOIN user_permissions up ON up.permission_id = rp.permission_id
-                LEFT JOIN role_hierarchy_permissions rhp ON rhp.permission_id = rp.permission_id;
-            `
-
-	rows, err := db.Query(permissionQuery, userPrivateToken, projectToken, resource, action)
-	
-	if err != nil {
-		log.Printf("[ERROR] Query execution failed: %v", err)
+	return CheckUserPermissions(db, userPrivateToken, projectToken, resource, action)
+}
+
+// CheckUserPermissions is CheckPermissions for an already resolved user.
+// Results are cached, see permission_cache.go.
+func CheckUserPermissions(db *sql.DB, userPrivateToken string, projectToken string, resource string, action string) bool {
+	if userPrivateToken == "" || projectToken == "" {
 		return false
 	}
 
-	defer rows.Close()
-
-	var hasPermission bool
-	var userLevel int
-	var roleName string
-	var roleDisplayName string
-	var directPermission sql.NullString
-	var userPermission sql.NullString
-	var inheritedPermission sql.NullString
-
-	rowCount := 0
-	for rows.Next() {
-		rowCount++
-		err := rows.Scan(&hasPermission, &userLevel, &roleName, &roleDisplayName, &directPermission, &userPermission, &inheritedPermission)
-		if err != nil {
-			log.Printf("[ERROR] Row scan failed on row %d: %v", rowCount, err)
-			return false
-		}
-
-		log.Printf("[DEBUG] Row %d: hasPermission=%v, userLevel=%d, roleName=%s, roleDisplayName=%s, directPermission=%v, userPermission=%v, inheritedPermission=%v",
-			rowCount, hasPermission, userLevel, roleName, roleDisplayName, 
-			directPermission.String, userPermission.String, inheritedPermission.String)
-
-		if hasPermission {
-			log.Printf("[INFO] Permission GRANTED for user %s on project %s for resource=%s, action=%s (via role: %s)",
-				userPrivateToken, projectToken, resource, action, roleName)
-			return true
-		}
+	key := permissionKey{user: userPrivateToken, project: projectToken, resource: resource, action: action}
+	if allowed, found := permissionCache.Get(key); found {
+		return allowed
 	}
 
-	if err = rows.Err(); err != nil {
-		log.Printf("[ERROR] Row iteration error: %v", err)
+	// Captured before reading so a change notified during the read keeps
+	// the result out of the cache
+	generation := permissionCache.Generation()
+
+	allowed, err := evaluatePermission(db, userPrivateToken, projectToken, resource, action)
+	if err != nil {
+		// Errors are not cached so the next request tries again
 		return false
 	}
 
-	if rowCount == 0 {
-		log.Printf("[INFO] No rows returned from permission query - user may not be a team member of this project")
-	} else {
-		log.Printf("[INFO] Permission DENIED for user %s on project %s for resource=%s, action=%s",
-			userPrivateToken, projectToken, resource, action)
+	permissionCache.Set(key, allowed, generation)
+	return allowed
+}
+
+func evaluatePermission(db *sql.DB, userPrivateToken string, projectToken string, resource string, action string) (bool, error) {
+	effective, err := GetEffectivePermissions(db, userPrivateToken, projectToken)
+	if err != nil {
+		log.Printf("[ERROR] Permission evaluation failed: %v", err)
+		return false, err
 	}
 
-	return false
-}
\ No newline at end of file
+	return effectiveAllows(effective, resource, action), nil
+}
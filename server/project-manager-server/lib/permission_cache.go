@@ -0,0 +1,206 @@
+package lib
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"project-manager-server/models"
+
+	"github.com/lib/pq"
+)
+
+// Channel the RBAC triggers notify on. The payload is the project token when
+// a single project is affected, empty when roles changed for everyone.
+const permissionChangesChannel = "rbac_changed"
+
+const defaultPermissionCacheTTL = 30 * time.Second
+
+// Expired entries are swept once the cache holds this many entries
+const permissionCacheSweepSize = 10000
+
+type permissionKey struct {
+	user     string
+	project  string
+	resource string
+	action   string
+}
+
+type permissionEntry struct {
+	allowed   bool
+	expiresAt time.Time
+}
+
+// PermissionCache holds the results of permission checks for a short time.
+// Changes to memberships and roles invalidate it through LISTEN/NOTIFY, the
+// TTL only bounds staleness while the listener is reconnecting.
+type PermissionCache struct {
+	mu      sync.RWMutex
+	entries map[permissionKey]permissionEntry
+	ttl     time.Duration
+	// Bumped by every invalidation, results computed before it moved may
+	// predate the change and are not stored
+	generation uint64
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+var permissionCache = NewPermissionCache(getPermissionCacheTTL())
+
+func getPermissionCacheTTL() time.Duration {
+	if value := os.Getenv("PERMISSION_CACHE_TTL"); value != "" {
+		if ttl, err := time.ParseDuration(value); err == nil {
+			return ttl
+		}
+		log.Printf("Invalid PERMISSION_CACHE_TTL %q, using %s", value, defaultPermissionCacheTTL)
+	}
+	return defaultPermissionCacheTTL
+}
+
+func NewPermissionCache(ttl time.Duration) *PermissionCache {
+	return &PermissionCache{
+		entries: make(map[permissionKey]permissionEntry),
+		ttl:     ttl,
+	}
+}
+
+func (c *PermissionCache) Get(key permissionKey) (bool, bool) {
+	c.mu.RLock()
+	entry, found := c.entries[key]
+	c.mu.RUnlock()
+
+	if !found || time.Now().After(entry.expiresAt) {
+		c.misses.Add(1)
+		return false, false
+	}
+
+	c.hits.Add(1)
+	return entry.allowed, true
+}
+
+// Generation is read before computing a result to pass to Set
+func (c *PermissionCache) Generation() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.generation
+}
+
+// Set stores a result computed when the cache was at generation. It is
+// dropped when an invalidation ran since, the result may be stale.
+func (c *PermissionCache) Set(key permissionKey, allowed bool, generation uint64) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	now := time.Now()
+	if len(c.entries) >= permissionCacheSweepSize {
+		for entryKey, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, entryKey)
+			}
+		}
+	}
+
+	c.entries[key] = permissionEntry{allowed: allowed, expiresAt: now.Add(c.ttl)}
+}
+
+// InvalidateProject drops the cached results of one project
+func (c *PermissionCache) InvalidateProject(projectToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.project == projectToken {
+			delete(c.entries, key)
+		}
+	}
+	c.generation++
+	c.invalidations.Add(1)
+}
+
+func (c *PermissionCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[permissionKey]permissionEntry)
+	c.generation++
+	c.invalidations.Add(1)
+}
+
+func (c *PermissionCache) Stats() models.PermissionCacheStats {
+	c.mu.RLock()
+	entries := len(c.entries)
+	c.mu.RUnlock()
+
+	hits := c.hits.Load()
+	misses := c.misses.Load()
+
+	var hitRatio float64
+	if hits+misses > 0 {
+		hitRatio = float64(hits) / float64(hits+misses)
+	}
+
+	return models.PermissionCacheStats{
+		Entries:       entries,
+		Hits:          hits,
+		Misses:        misses,
+		HitRatio:      hitRatio,
+		Invalidations: c.invalidations.Load(),
+		TTLSeconds:    c.ttl.Seconds(),
+	}
+}
+
+func GetPermissionCacheStats() models.PermissionCacheStats {
+	return permissionCache.Stats()
+}
+
+// ListenPermissionChanges invalidates the permission cache on notifications
+// from the RBAC triggers until ctx is done
+func ListenPermissionChanges(ctx context.Context, dataSourceName string) {
+	listener := pq.NewListener(dataSourceName, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("[ERROR] Permission change listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(permissionChangesChannel); err != nil {
+		log.Printf("[ERROR] Cannot listen for permission changes, relying on the cache TTL: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case notification := <-listener.Notify:
+			switch {
+			case notification == nil:
+				// The connection was re-established, changes may have been missed
+				permissionCache.Clear()
+			case notification.Extra == "":
+				permissionCache.Clear()
+			default:
+				permissionCache.InvalidateProject(notification.Extra)
+			}
+
+		case <-time.After(90 * time.Second):
+			// Detects broken connections that would otherwise go unnoticed
+			go listener.Ping()
+		}
+	}
+}
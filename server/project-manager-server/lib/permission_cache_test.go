@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPermissionCacheDropsResultsComputedBeforeInvalidation(t *testing.T) {
+	cache := NewPermissionCache(time.Minute)
+	key := permissionKey{user: "user", project: testProjectToken, resource: "code", action: "update"}
+	other := permissionKey{user: "user", project: "other-project", resource: "code", action: "update"}
+
+	tests := []struct {
+		name       string
+		invalidate func()
+	}{
+		{"project invalidated", func() { cache.InvalidateProject(testProjectToken) }},
+		{"unrelated project invalidated", func() { cache.InvalidateProject("other-project") }},
+		{"cache cleared", cache.Clear},
+	}
+
+	for _, test := range tests {
+		// A check read the database, then a revocation was notified before
+		// it stored its result
+		generation := cache.Generation()
+		test.invalidate()
+		cache.Set(key, true, generation)
+
+		if _, found := cache.Get(key); found {
+			t.Errorf("%s: result computed before the invalidation was cached", test.name)
+		}
+	}
+
+	generation := cache.Generation()
+	cache.Set(key, true, generation)
+	cache.Set(other, false, generation)
+	if allowed, found := cache.Get(key); !found || !allowed {
+		t.Errorf("Get = %v, %v after a Set at the current generation", allowed, found)
+	}
+
+	cache.InvalidateProject(testProjectToken)
+	if _, found := cache.Get(key); found {
+		t.Error("entry of the invalidated project is still cached")
+	}
+	if _, found := cache.Get(other); !found {
+		t.Error("entry of another project was dropped")
+	}
+}
+
+func TestPermissionCacheExpiresEntries(t *testing.T) {
+	cache := NewPermissionCache(time.Millisecond)
+	key := permissionKey{user: "user", project: testProjectToken, resource: "code", action: "read"}
+
+	cache.Set(key, true, cache.Generation())
+	time.Sleep(5 * time.Millisecond)
+
+	if _, found := cache.Get(key); found {
+		t.Error("expired entry returned")
+	}
+
+	disabled := NewPermissionCache(0)
+	disabled.Set(key, true, disabled.Generation())
+	if _, found := disabled.Get(key); found {
+		t.Error("cache with a zero TTL stored an entry")
+	}
+}
@@ -0,0 +1,414 @@
+package lib
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"project-manager-server/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrReleaseNotFound  = errors.New("release not found")
+	ErrReleaseExists    = errors.New("the tag already has a release")
+	ErrInvalidRelease   = errors.New("invalid release")
+	ErrArtifactNotFound = errors.New("artifact not found")
+	ErrTagHasRelease    = errors.New("the tag has a release, delete the release first")
+
+	ErrReleasesFolderUnset = errors.New("RELEASES_FOLDER_PATH is not set")
+)
+
+const releaseColumns = `
+	r.id, r.tag_name, r.name, r.notes, r.status, u.UserName, r.created_at, r.updated_at, r.published_at
+`
+
+func scanRelease(row interface{ Scan(...any) error }) (models.Release, error) {
+	var release models.Release
+	err := row.Scan(&release.ID, &release.TagName, &release.Name, &release.Notes, &release.Status,
+		&release.CreatedBy, &release.CreatedAt, &release.UpdatedAt, &release.PublishedAt)
+	release.Artifacts = []models.ReleaseArtifact{}
+	return release, err
+}
+
+// ListReleases returns the releases of a project, newest first. Drafts are
+// left out unless includeDrafts is set.
+func ListReleases(db *sql.DB, projectToken string, includeDrafts bool) ([]models.Release, error) {
+	query := `
+		SELECT ` + releaseColumns + `
+		FROM project_releases r
+		INNER JOIN users u ON u.UserPrivateToken = r.created_by
+		WHERE r.projecttoken = $1 AND ($2 OR r.status = 'published')
+		ORDER BY COALESCE(r.published_at, r.created_at) DESC;
+	`
+
+	rows, err := db.Query(query, projectToken, includeDrafts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []models.Release{}
+	positions := make(map[int]int)
+	for rows.Next() {
+		release, err := scanRelease(rows)
+		if err != nil {
+			return nil, err
+		}
+		positions[release.ID] = len(releases)
+		releases = append(releases, release)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	artifacts, err := queryArtifacts(db, "r.projecttoken = $1", projectToken)
+	if err != nil {
+		return nil, err
+	}
+	for releaseID, releaseArtifacts := range artifacts {
+		if i, found := positions[releaseID]; found {
+			releases[i].Artifacts = releaseArtifacts
+		}
+	}
+
+	return releases, nil
+}
+
+func GetRelease(db *sql.DB, projectToken string, releaseID int) (*models.Release, error) {
+	query := `
+		SELECT ` + releaseColumns + `
+		FROM project_releases r
+		INNER JOIN users u ON u.UserPrivateToken = r.created_by
+		WHERE r.id = $1 AND r.projecttoken = $2;
+	`
+
+	release, err := scanRelease(db.QueryRow(query, releaseID, projectToken))
+	if err == sql.ErrNoRows {
+		return nil, ErrReleaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts, err := queryArtifacts(db, "r.id = $1", releaseID)
+	if err != nil {
+		return nil, err
+	}
+	if releaseArtifacts, found := artifacts[release.ID]; found {
+		release.Artifacts = releaseArtifacts
+	}
+
+	return &release, nil
+}
+
+// queryArtifacts returns artifacts grouped by release
+func queryArtifacts(db *sql.DB, condition string, arg any) (map[int][]models.ReleaseArtifact, error) {
+	query := `
+		SELECT a.id, a.release_id, r.projecttoken, a.file_name, a.storage_path, a.size_bytes, a.content_type, a.created_at
+		FROM project_release_artifacts a
+		INNER JOIN project_releases r ON r.id = a.release_id
+		WHERE ` + condition + `
+		ORDER BY a.created_at, a.id;
+	`
+
+	rows, err := db.Query(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artifacts := make(map[int][]models.ReleaseArtifact)
+	for rows.Next() {
+		var artifact models.ReleaseArtifact
+		var releaseID int
+		var projectToken string
+		if err := rows.Scan(&artifact.ID, &releaseID, &projectToken, &artifact.FileName, &artifact.StoragePath,
+			&artifact.SizeBytes, &artifact.ContentType, &artifact.CreatedAt); err != nil {
+			return nil, err
+		}
+		artifact.URL = artifactURL(projectToken, releaseID, artifact.ID)
+		artifacts[releaseID] = append(artifacts[releaseID], artifact)
+	}
+
+	return artifacts, rows.Err()
+}
+
+// CreateRelease adds a release for a tag the caller checked exists
+func CreateRelease(db *sql.DB, projectToken string, createdBy string, request models.CreateReleaseRequest) (*models.Release, error) {
+	if request.Name == "" {
+		request.Name = request.TagName
+	}
+	if request.Status == "" {
+		request.Status = models.ReleaseDraft
+	}
+	if err := validateRelease(request.Name, request.Status); err != nil {
+		return nil, err
+	}
+
+	const query = `
+		INSERT INTO project_releases (projecttoken, tag_name, name, notes, status, created_by, published_at)
+		VALUES ($1, $2, $3, $4, $5::text, $6, CASE WHEN $5::text = 'published' THEN CURRENT_TIMESTAMP END)
+		RETURNING id;
+	`
+
+	var releaseID int
+	err := db.QueryRow(query, projectToken, request.TagName, request.Name, request.Notes, request.Status, createdBy).Scan(&releaseID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrReleaseExists
+		}
+		return nil, err
+	}
+
+	return GetRelease(db, projectToken, releaseID)
+}
+
+// UpdateRelease changes the fields of request that are set. Publishing
+// stamps the release the first time, turning it back into a draft clears it.
+func UpdateRelease(db *sql.DB, projectToken string, releaseID int, request models.UpdateReleaseRequest) (*models.Release, error) {
+	name, status := "release", models.ReleaseDraft
+	if request.Name != nil {
+		name = *request.Name
+	}
+	if request.Status != nil {
+		status = *request.Status
+	}
+	if err := validateRelease(name, status); err != nil {
+		return nil, err
+	}
+
+	const query = `
+		UPDATE project_releases SET
+			name = COALESCE($3, name),
+			notes = COALESCE($4, notes),
+			status = COALESCE($5::text, status),
+			published_at = CASE
+				WHEN COALESCE($5::text, status) = 'published' THEN COALESCE(published_at, CURRENT_TIMESTAMP)
+			END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND projecttoken = $2;
+	`
+
+	result, err := db.Exec(query, releaseID, projectToken, request.Name, request.Notes, request.Status)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, ErrReleaseNotFound
+	}
+
+	return GetRelease(db, projectToken, releaseID)
+}
+
+func validateRelease(name string, status string) error {
+	if strings.TrimSpace(name) == "" || len(name) > 200 {
+		return fmt.Errorf("%w: name must be between 1 and 200 characters", ErrInvalidRelease)
+	}
+	if status != models.ReleaseDraft && status != models.ReleasePublished {
+		return fmt.Errorf("%w: status must be %q or %q", ErrInvalidRelease, models.ReleaseDraft, models.ReleasePublished)
+	}
+	return nil
+}
+
+// DeleteRelease removes a release and the files of its artifacts. The tag
+// is left alone.
+func DeleteRelease(db *sql.DB, projectToken string, releaseID int) error {
+	release, err := GetRelease(db, projectToken, releaseID)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec("DELETE FROM project_releases WHERE id = $1 AND projecttoken = $2;", releaseID, projectToken)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrReleaseNotFound
+	}
+
+	for _, artifact := range release.Artifacts {
+		removeArtifactFile(artifact.StoragePath)
+	}
+	return nil
+}
+
+// ReleaseTagInUse reports whether a release ships tagName
+func ReleaseTagInUse(db *sql.DB, projectToken string, tagName string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM project_releases WHERE projecttoken = $1 AND tag_name = $2);", projectToken, tagName).Scan(&exists)
+	return exists, err
+}
+
+// AddReleaseArtifact stores an uploaded build artifact in the releases folder
+// and attaches it to the release
+func AddReleaseArtifact(db *sql.DB, projectToken string, releaseID int, uploadedBy string, fileName string, contentType string, content io.Reader) (*models.ReleaseArtifact, error) {
+	if _, err := GetRelease(db, projectToken, releaseID); err != nil {
+		return nil, err
+	}
+
+	fileName = filepath.Base(strings.ReplaceAll(fileName, `\`, "/"))
+	if cleaned, err := CleanRelativePath(fileName); err != nil || cleaned != fileName || strings.HasPrefix(fileName, ".") {
+		return nil, fmt.Errorf("%w: invalid artifact file name", ErrInvalidRelease)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// A random folder lets an artifact be replaced without reusing its path
+	storagePath := path.Join(projectToken, fmt.Sprint(releaseID), uuid.New().String(), fileName)
+	fullPath, err := artifactPath(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		removeArtifactFile(storagePath)
+		return nil, err
+	}
+
+	artifact := &models.ReleaseArtifact{
+		FileName:    fileName,
+		SizeBytes:   size,
+		ContentType: contentType,
+		StoragePath: storagePath,
+	}
+
+	const query = `
+		INSERT INTO project_release_artifacts (release_id, file_name, storage_path, size_bytes, content_type, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at;
+	`
+
+	err = db.QueryRow(query, releaseID, fileName, storagePath, size, contentType, uploadedBy).Scan(&artifact.ID, &artifact.CreatedAt)
+	if err != nil {
+		removeArtifactFile(storagePath)
+		return nil, err
+	}
+	artifact.URL = artifactURL(projectToken, releaseID, artifact.ID)
+
+	return artifact, nil
+}
+
+// OpenReleaseArtifact returns an artifact and its opened file. Artifacts of
+// drafts are reported missing unless includeDrafts is set.
+func OpenReleaseArtifact(db *sql.DB, projectToken string, releaseID int, artifactID int, includeDrafts bool) (*models.ReleaseArtifact, *os.File, error) {
+	const query = `
+		SELECT a.id, a.file_name, a.storage_path, a.size_bytes, a.content_type, a.created_at
+		FROM project_release_artifacts a
+		INNER JOIN project_releases r ON r.id = a.release_id
+		WHERE a.id = $1 AND a.release_id = $2 AND r.projecttoken = $3 AND ($4 OR r.status = 'published');
+	`
+
+	var artifact models.ReleaseArtifact
+	err := db.QueryRow(query, artifactID, releaseID, projectToken, includeDrafts).Scan(&artifact.ID, &artifact.FileName,
+		&artifact.StoragePath, &artifact.SizeBytes, &artifact.ContentType, &artifact.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	artifact.URL = artifactURL(projectToken, releaseID, artifact.ID)
+
+	fullPath, err := artifactPath(artifact.StoragePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(fullPath)
+	if os.IsNotExist(err) {
+		log.Printf("[ERROR] Release artifact %d is missing its file %s", artifact.ID, artifact.StoragePath)
+		return nil, nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &artifact, file, nil
+}
+
+func DeleteReleaseArtifact(db *sql.DB, projectToken string, releaseID int, artifactID int) error {
+	const query = `
+		DELETE FROM project_release_artifacts a
+		USING project_releases r
+		WHERE a.id = $1 AND a.release_id = $2 AND r.id = a.release_id AND r.projecttoken = $3
+		RETURNING a.storage_path;
+	`
+
+	var storagePath string
+	err := db.QueryRow(query, artifactID, releaseID, projectToken).Scan(&storagePath)
+	if err == sql.ErrNoRows {
+		return ErrArtifactNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	removeArtifactFile(storagePath)
+	return nil
+}
+
+// removeArtifactFile deletes the file and its random folder. Failures are
+// only logged, the database no longer references the file.
+func removeArtifactFile(storagePath string) {
+	folder, err := artifactPath(path.Dir(storagePath))
+	if err != nil {
+		log.Printf("[ERROR] Cannot remove release artifact %s: %v", storagePath, err)
+		return
+	}
+
+	if err := os.RemoveAll(folder); err != nil {
+		log.Printf("[ERROR] Cannot remove release artifact %s: %v", storagePath, err)
+	}
+}
+
+// artifactPath resolves a storage path, <project>/<release>/<random>/<file>,
+// below RELEASES_FOLDER_PATH.
+//
+// Artifacts used to live below the projects folder the file server serves
+// without authentication, which handed out the files of drafts to anyone
+// with the URL. The file server has no notion of releases or permissions, so
+// storage moved here and DownloadReleaseArtifact streams the files after
+// checking the release is published or the user may edit releases. The
+// folder must not be mounted into the file server.
+func artifactPath(storagePath string) (string, error) {
+	releasesFolder := os.Getenv("RELEASES_FOLDER_PATH")
+	if releasesFolder == "" {
+		return "", ErrReleasesFolderUnset
+	}
+
+	// Resolving rejects paths that would name the project folder itself
+	projectToken, relPath, _ := strings.Cut(storagePath, "/")
+	return ResolveWorkspacePath(releasesFolder, projectToken, relPath)
+}
+
+func artifactURL(projectToken string, releaseID int, artifactID int) string {
+	return fmt.Sprintf("/api/projects/releases/%d/artifacts/%d?projectToken=%s", releaseID, artifactID, url.QueryEscape(projectToken))
+}
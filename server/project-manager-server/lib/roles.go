@@ -0,0 +1,385 @@
+package lib
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"project-manager-server/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSystemRole         = errors.New("global and system roles cannot be changed")
+	ErrRoleExists         = errors.New("a role with this name already exists")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrInvalidRole        = errors.New("invalid role")
+
+	ErrRoleInheritanceCycle = errors.New("role inheritance would create a cycle")
+)
+
+// Same convention as the seeded roles, e.g. RELEASE_MANAGER
+var roleNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,99}$`)
+
+// Values of rbac_audit_log.action_type for role changes
+const (
+	auditRoleCreated = "role_created"
+	auditRoleUpdated = "role_updated"
+)
+
+// ListRoles returns the global roles and the roles defined by the project
+func ListRoles(db *sql.DB, projectToken string) ([]models.Role, error) {
+	const query = `
+		SELECT r.id, r.name, r.display_name, COALESCE(r.description, ''), r.level,
+			COALESCE(r.is_system_role, false), r.project_token,
+			ARRAY(
+				SELECT pr.name FROM role_inheritance ri
+				JOIN roles pr ON pr.id = ri.parent_role_id
+				WHERE ri.child_role_id = r.id
+				ORDER BY pr.name
+			),
+			ARRAY(
+				SELECT res.name || ':' || a.name FROM role_permissions rp
+				JOIN permissions p ON p.id = rp.permission_id
+				JOIN resources res ON res.id = p.resource_id
+				JOIN actions a ON a.id = p.action_id
+				WHERE rp.role_id = r.id
+				ORDER BY 1
+			)
+		FROM roles r
+		WHERE r.is_active = true
+		AND (r.project_token IS NULL OR r.project_token = $1)
+		ORDER BY r.project_token NULLS FIRST, r.level DESC, r.name;
+	`
+
+	rows, err := db.Query(query, projectToken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.DisplayName, &role.Description, &role.Level,
+			&role.IsSystemRole, &role.ProjectToken, pq.Array(&role.Parents), pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// CreateRole defines a role for one project. The performer can only hand
+// out permissions and levels they hold themselves, unless they own the
+// project.
+func CreateRole(db *sql.DB, request models.RoleRequest, performedBy string) (int, error) {
+	if !roleNamePattern.MatchString(request.Name) {
+		return 0, fmt.Errorf("%w: name must be upper case letters, digits and underscores", ErrInvalidRole)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Project roles cannot shadow global ones, the PROJECT_OWNER and GUEST
+	// rules match on the name
+	var exists bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM roles WHERE name = $1
+			AND (project_token IS NULL OR project_token = $2)
+		);
+	`, request.Name, request.ProjectToken).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, ErrRoleExists
+	}
+
+	if err := validateRoleRequest(db, tx, request, performedBy); err != nil {
+		return 0, err
+	}
+
+	var roleID int
+	err = tx.QueryRow(`
+		INSERT INTO roles (name, display_name, description, level, is_system_role, project_token)
+		VALUES ($1, $2, $3, $4, false, $5)
+		RETURNING id;
+	`, request.Name, request.DisplayName, request.Description, request.Level, request.ProjectToken).Scan(&roleID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := saveRoleGrants(tx, roleID, request); err != nil {
+		return 0, err
+	}
+
+	if err := writeAuditLog(tx, request.ProjectToken, "", auditRoleCreated, nil, &roleID, performedBy, request.Reason); err != nil {
+		return 0, err
+	}
+
+	return roleID, tx.Commit()
+}
+
+// CloneRole creates a project role with the level, description, parents and
+// permissions of an existing role
+func CloneRole(db *sql.DB, request models.CloneRoleRequest, performedBy string) (int, error) {
+	roles, err := ListRoles(db, request.ProjectToken)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, source := range roles {
+		if source.Name != request.SourceRole {
+			continue
+		}
+
+		displayName := request.DisplayName
+		if displayName == "" {
+			displayName = source.DisplayName
+		}
+
+		return CreateRole(db, models.RoleRequest{
+			ProjectToken: request.ProjectToken,
+			Name:         request.Name,
+			DisplayName:  displayName,
+			Description:  source.Description,
+			Level:        source.Level,
+			Parents:      source.Parents,
+			Permissions:  source.Permissions,
+			Reason:       request.Reason,
+		}, performedBy)
+	}
+
+	return 0, ErrRoleNotFound
+}
+
+// UpdateRole replaces the display name, description, level, parents and
+// permissions of a project role. The name stays, members refer to it.
+func UpdateRole(db *sql.DB, roleID int, request models.RoleRequest, performedBy string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var projectToken sql.NullString
+	var isSystemRole bool
+	err = tx.QueryRow(`
+		SELECT project_token, COALESCE(is_system_role, false) FROM roles
+		WHERE id = $1 AND is_active = true
+		FOR UPDATE;
+	`, roleID).Scan(&projectToken, &isSystemRole)
+	if err == sql.ErrNoRows {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !projectToken.Valid || isSystemRole {
+		return ErrSystemRole
+	}
+	if projectToken.String != request.ProjectToken {
+		return ErrRoleNotFound
+	}
+
+	if err := validateRoleRequest(db, tx, request, performedBy); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE roles SET display_name = $1, description = $2, level = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4;
+	`, request.DisplayName, request.Description, request.Level, roleID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role_id = $1;", roleID); err != nil {
+		return err
+	}
+
+	if err := saveRoleGrants(tx, roleID, request); err != nil {
+		return err
+	}
+
+	if err := writeAuditLog(tx, request.ProjectToken, "", auditRoleUpdated, &roleID, &roleID, performedBy, request.Reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func validateRoleRequest(db *sql.DB, tx *sql.Tx, request models.RoleRequest, performedBy string) error {
+	if strings.TrimSpace(request.DisplayName) == "" || len(request.DisplayName) > 100 {
+		return fmt.Errorf("%w: displayName is required and must be at most 100 characters", ErrInvalidRole)
+	}
+
+	var ownerLevel int
+	if err := tx.QueryRow("SELECT level FROM roles WHERE name = $1 AND project_token IS NULL;", ownerRoleName).Scan(&ownerLevel); err != nil {
+		return err
+	}
+	if request.Level < 1 || request.Level >= ownerLevel {
+		return fmt.Errorf("%w: level must be between 1 and %d", ErrInvalidRole, ownerLevel-1)
+	}
+
+	performer, err := GetEffectivePermissions(db, performedBy, request.ProjectToken)
+	if err != nil {
+		return err
+	}
+	if performer.IsOwner {
+		return nil
+	}
+
+	var performerLevel int
+	if err := tx.QueryRow("SELECT level FROM roles WHERE name = $1 AND (project_token IS NULL OR project_token = $2);",
+		performer.Role, request.ProjectToken).Scan(&performerLevel); err != nil {
+		return err
+	}
+	if request.Level > performerLevel {
+		return ErrRoleAboveOwn
+	}
+
+	parentIDs := make([]int, 0, len(request.Parents))
+	for _, parentName := range request.Parents {
+		parent, err := getRoleByName(tx, request.ProjectToken, parentName)
+		if err != nil {
+			return err
+		}
+		if parent.level > performerLevel {
+			return ErrRoleAboveOwn
+		}
+		parentIDs = append(parentIDs, parent.id)
+	}
+
+	// Parents hand down everything they hold, so their grants are checked
+	// like the ones given directly
+	inherited, err := inheritedPermissions(tx, parentIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, permission := range slices.Concat(request.Permissions, inherited) {
+		resource, action, _ := strings.Cut(permission, ":")
+		if !effectiveAllows(performer, resource, action) {
+			return fmt.Errorf("%w: you do not hold %s yourself", ErrRoleAboveOwn, permission)
+		}
+	}
+
+	return nil
+}
+
+// inheritedPermissions returns the grants of the given roles and of every
+// role they inherit from, as resource:action
+func inheritedPermissions(tx *sql.Tx, roleIDs []int) ([]string, error) {
+	if len(roleIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+		WITH RECURSIVE ancestors (role_id, path) AS (
+			SELECT id, ARRAY[id] FROM roles WHERE id = ANY($1::int[])
+			UNION ALL
+			SELECT ri.parent_role_id, a.path || ri.parent_role_id
+			FROM ancestors a
+			JOIN role_inheritance ri ON ri.child_role_id = a.role_id
+			WHERE NOT ri.parent_role_id = ANY(a.path)
+		)
+		SELECT DISTINCT res.name || ':' || act.name
+		FROM ancestors a
+		JOIN role_permissions rp ON rp.role_id = a.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		JOIN resources res ON res.id = p.resource_id
+		JOIN actions act ON act.id = p.action_id
+		ORDER BY 1;
+	`, pq.Array(roleIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []string
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+// saveRoleGrants writes the permissions and replaces the parents of a role
+func saveRoleGrants(tx *sql.Tx, roleID int, request models.RoleRequest) error {
+	for _, permission := range request.Permissions {
+		resource, action, _ := strings.Cut(permission, ":")
+
+		result, err := tx.Exec(`
+			INSERT INTO role_permissions (role_id, permission_id)
+			SELECT $1, p.id FROM permissions p
+			JOIN resources res ON res.id = p.resource_id
+			JOIN actions a ON a.id = p.action_id
+			WHERE res.name = $2 AND a.name = $3
+			ON CONFLICT DO NOTHING;
+		`, roleID, resource, action)
+		if err != nil {
+			return err
+		}
+
+		if inserted, _ := result.RowsAffected(); inserted == 0 {
+			var exists bool
+			if err := tx.QueryRow(`
+				SELECT EXISTS (
+					SELECT 1 FROM permissions p
+					JOIN resources res ON res.id = p.resource_id
+					JOIN actions a ON a.id = p.action_id
+					WHERE res.name = $1 AND a.name = $2
+				);
+			`, resource, action).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("%w: %s", ErrPermissionNotFound, permission)
+			}
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM role_inheritance WHERE child_role_id = $1;", roleID); err != nil {
+		return err
+	}
+
+	for _, parentName := range request.Parents {
+		parent, err := getRoleByName(tx, request.ProjectToken, parentName)
+		if err != nil {
+			return fmt.Errorf("parent %s: %w", parentName, err)
+		}
+		if parent.name == ownerRoleName {
+			return ErrOwnerRole
+		}
+
+		// The role_inheritance_cycle_check trigger rejects parents that
+		// would close a cycle, serializing concurrent writers
+		_, err = tx.Exec(`
+			INSERT INTO role_inheritance (parent_role_id, child_role_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING;
+		`, parent.id, roleID)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23514" {
+			return ErrRoleInheritanceCycle
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	"project-manager-server/models"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrSSHKeyExists = errors.New("ssh key is already registered")
+
+// AddSSHKey parses a public key in authorized_keys format and registers it
+// for the user. A key can only belong to one user.
+func AddSSHKey(db *sql.DB, userPrivateToken string, name string, authorizedKey string) (models.SSHKey, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return models.SSHKey{}, err
+	}
+
+	sshKey := models.SSHKey{
+		Name: name,
+		// Drop the comment and options, only the key itself is kept
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+	}
+
+	const query = `
+		INSERT INTO user_ssh_keys (userprivatetoken, name, public_key, fingerprint)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at;
+	`
+
+	err = db.QueryRow(query, userPrivateToken, sshKey.Name, sshKey.PublicKey, sshKey.Fingerprint).Scan(&sshKey.ID, &sshKey.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return models.SSHKey{}, ErrSSHKeyExists
+		}
+		return models.SSHKey{}, err
+	}
+
+	return sshKey, nil
+}
+
+func ListSSHKeys(db *sql.DB, userPrivateToken string) ([]models.SSHKey, error) {
+	const query = `
+		SELECT id, name, public_key, fingerprint, last_used_at, created_at
+		FROM user_ssh_keys
+		WHERE userprivatetoken = $1
+		ORDER BY created_at DESC;
+	`
+
+	rows, err := db.Query(query, userPrivateToken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sshKeys := []models.SSHKey{}
+	for rows.Next() {
+		var sshKey models.SSHKey
+		if err := rows.Scan(&sshKey.ID, &sshKey.Name, &sshKey.PublicKey, &sshKey.Fingerprint, &sshKey.LastUsedAt, &sshKey.CreatedAt); err != nil {
+			return nil, err
+		}
+		sshKeys = append(sshKeys, sshKey)
+	}
+
+	return sshKeys, rows.Err()
+}
+
+// DeleteSSHKey returns false when the user has no key with this id
+func DeleteSSHKey(db *sql.DB, userPrivateToken string, id int) (bool, error) {
+	result, err := db.Exec("DELETE FROM user_ssh_keys WHERE id = $1 AND userprivatetoken = $2;", id, userPrivateToken)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetPrivateTokenBySSHKey returns the owner of a registered public key and
+// marks the key as used, or an empty string for unknown keys
+func GetPrivateTokenBySSHKey(db *sql.DB, publicKey ssh.PublicKey) string {
+	const query = `
+		UPDATE user_ssh_keys SET last_used_at = CURRENT_TIMESTAMP
+		WHERE fingerprint = $1
+		RETURNING userprivatetoken, public_key;
+	`
+
+	var userPrivateToken, storedKey string
+	if err := db.QueryRow(query, ssh.FingerprintSHA256(publicKey)).Scan(&userPrivateToken, &storedKey); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] SSH key lookup failed: %v", err)
+		}
+		return ""
+	}
+
+	// The fingerprint is a hash, compare the full key to be certain
+	if storedKey != strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))) {
+		return ""
+	}
+
+	return userPrivateToken
+}
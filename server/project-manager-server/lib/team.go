@@ -0,0 +1,407 @@
+package lib
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"project-manager-server/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrRoleNotFound   = errors.New("role not found")
+	ErrMemberNotFound = errors.New("user is not a member of the project")
+	ErrMemberExists   = errors.New("user is already a member of the project")
+	ErrOwnerRole      = errors.New("the owner role only changes through an ownership transfer")
+	ErrRoleAboveOwn   = errors.New("cannot assign a role above your own")
+	ErrMemberAboveOwn = errors.New("cannot change a member whose role is at or above your own")
+	ErrNotOwner       = errors.New("only the project owner can transfer ownership")
+)
+
+// Role the previous owner keeps after a transfer unless another one is given
+const defaultPreviousOwnerRole = "PROJECT_MANAGER"
+
+// Values of rbac_audit_log.action_type
+const (
+	auditMemberInvited        = "member_invited"
+	auditRoleChanged          = "role_changed"
+	auditMemberDeactivated    = "member_deactivated"
+	auditMemberReactivated    = "member_reactivated"
+	auditOwnershipTransferred = "ownership_transferred"
+)
+
+type teamRole struct {
+	id    int
+	name  string
+	level int
+}
+
+type teamMember struct {
+	role     teamRole
+	isActive bool
+}
+
+func ListProjectMembers(db *sql.DB, projectToken string) ([]models.ProjectMember, error) {
+	const query = `
+		SELECT u.UserPublicToken, u.UserName, u.UserEmail, r.name, r.display_name, ptm.is_active, ptm.assigned_at
+		FROM projects_team_members ptm
+		JOIN users u ON u.UserPrivateToken = ptm.userprivatetoken
+		JOIN roles r ON r.id = ptm.role_id
+		WHERE ptm.projecttoken = $1
+		ORDER BY r.level DESC, u.UserName;
+	`
+
+	rows, err := db.Query(query, projectToken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.ProjectMember{}
+	for rows.Next() {
+		var member models.ProjectMember
+		if err := rows.Scan(&member.UserPublicToken, &member.UserName, &member.UserEmail, &member.Role,
+			&member.RoleDisplayName, &member.IsActive, &member.AssignedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// MembersByEmail maps lowercased emails to the project members using them.
+// Only members are looked up so blame does not reveal who else has an
+// account.
+func MembersByEmail(db *sql.DB, projectToken string, emails []string) (map[string]models.BlameUser, error) {
+	users := make(map[string]models.BlameUser)
+	if len(emails) == 0 {
+		return users, nil
+	}
+
+	lowered := make([]string, len(emails))
+	for i, email := range emails {
+		lowered[i] = strings.ToLower(email)
+	}
+
+	const query = `
+		SELECT LOWER(u.UserEmail), u.UserPublicToken, u.UserName
+		FROM projects_team_members ptm
+		JOIN users u ON u.UserPrivateToken = ptm.userprivatetoken
+		WHERE ptm.projecttoken = $1 AND LOWER(u.UserEmail) = ANY($2);
+	`
+
+	rows, err := db.Query(query, projectToken, pq.Array(lowered))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email string
+		var user models.BlameUser
+		if err := rows.Scan(&email, &user.UserPublicToken, &user.UserName); err != nil {
+			return nil, err
+		}
+		users[email] = user
+	}
+
+	return users, rows.Err()
+}
+
+// InviteMember adds the user with this email to the project
+func InviteMember(db *sql.DB, projectToken string, email string, roleName string, performedBy string, reason string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userPrivateToken string
+	err = tx.QueryRow("SELECT UserPrivateToken FROM users WHERE LOWER(UserEmail) = LOWER($1);", strings.TrimSpace(email)).Scan(&userPrivateToken)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	role, err := assignableRole(tx, projectToken, roleName, performedBy)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO projects_team_members (projecttoken, userprivatetoken, role_id, assigned_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (projecttoken, userprivatetoken) DO NOTHING;
+	`, projectToken, userPrivateToken, role.id, performedBy)
+	if err != nil {
+		return err
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return ErrMemberExists
+	}
+
+	if err := writeAuditLog(tx, projectToken, userPrivateToken, auditMemberInvited, nil, &role.id, performedBy, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ChangeMemberRole(db *sql.DB, projectToken string, userPrivateToken string, roleName string, performedBy string, reason string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	member, err := lockMember(tx, projectToken, userPrivateToken)
+	if err != nil {
+		return err
+	}
+	if member.role.name == ownerRoleName {
+		return ErrOwnerRole
+	}
+	if err := checkMemberBelowPerformer(tx, projectToken, member, performedBy); err != nil {
+		return err
+	}
+
+	role, err := assignableRole(tx, projectToken, roleName, performedBy)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE projects_team_members SET role_id = $1, assigned_by = $2, assigned_at = CURRENT_TIMESTAMP
+		WHERE projecttoken = $3 AND userprivatetoken = $4;
+	`, role.id, performedBy, projectToken, userPrivateToken); err != nil {
+		return err
+	}
+
+	if err := writeAuditLog(tx, projectToken, userPrivateToken, auditRoleChanged, &member.role.id, &role.id, performedBy, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetMemberActive deactivates or reactivates a membership. Inactive members
+// keep their role but fail every permission check.
+func SetMemberActive(db *sql.DB, projectToken string, userPrivateToken string, active bool, performedBy string, reason string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	member, err := lockMember(tx, projectToken, userPrivateToken)
+	if err != nil {
+		return err
+	}
+	if member.role.name == ownerRoleName {
+		return ErrOwnerRole
+	}
+	if err := checkMemberBelowPerformer(tx, projectToken, member, performedBy); err != nil {
+		return err
+	}
+
+	// Reactivating restores the member's role, which must still be one the
+	// performer could assign
+	if active {
+		if _, err := assignableRole(tx, projectToken, member.role.name, performedBy); err != nil {
+			return err
+		}
+	}
+
+	if member.isActive == active {
+		return tx.Commit()
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE projects_team_members SET is_active = $1
+		WHERE projecttoken = $2 AND userprivatetoken = $3;
+	`, active, projectToken, userPrivateToken); err != nil {
+		return err
+	}
+
+	actionType := auditMemberDeactivated
+	if active {
+		actionType = auditMemberReactivated
+	}
+	if err := writeAuditLog(tx, projectToken, userPrivateToken, actionType, &member.role.id, &member.role.id, performedBy, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TransferOwnership makes an active member the owner. The previous owner,
+// who must be the one performing the transfer, keeps previousOwnerRole.
+func TransferOwnership(db *sql.DB, projectToken string, newOwnerPrivateToken string, previousOwnerRole string, performedBy string, reason string) error {
+	if previousOwnerRole == "" {
+		previousOwnerRole = defaultPreviousOwnerRole
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	owner, err := lockMember(tx, projectToken, performedBy)
+	if err == ErrMemberNotFound || (err == nil && (owner.role.name != ownerRoleName || !owner.isActive)) {
+		return ErrNotOwner
+	}
+	if err != nil {
+		return err
+	}
+
+	newOwner, err := lockMember(tx, projectToken, newOwnerPrivateToken)
+	if err != nil {
+		return err
+	}
+	if !newOwner.isActive {
+		return ErrMemberNotFound
+	}
+	if newOwnerPrivateToken == performedBy {
+		return tx.Commit()
+	}
+
+	previousRole, err := getRoleByName(tx, projectToken, previousOwnerRole)
+	if err != nil {
+		return err
+	}
+	if previousRole.name == ownerRoleName {
+		return ErrOwnerRole
+	}
+
+	const updateRole = `
+		UPDATE projects_team_members SET role_id = $1, assigned_by = $2, assigned_at = CURRENT_TIMESTAMP
+		WHERE projecttoken = $3 AND userprivatetoken = $4;
+	`
+	if _, err := tx.Exec(updateRole, owner.role.id, performedBy, projectToken, newOwnerPrivateToken); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(updateRole, previousRole.id, performedBy, projectToken, performedBy); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE projects SET ProjectOwnerToken = $1 WHERE ProjectToken = $2;", newOwnerPrivateToken, projectToken); err != nil {
+		return err
+	}
+
+	if err := writeAuditLog(tx, projectToken, newOwnerPrivateToken, auditOwnershipTransferred, &newOwner.role.id, &owner.role.id, performedBy, reason); err != nil {
+		return err
+	}
+	if err := writeAuditLog(tx, projectToken, performedBy, auditRoleChanged, &owner.role.id, &previousRole.id, performedBy, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getRoleByName finds a global role or a role defined by the project
+func getRoleByName(tx *sql.Tx, projectToken string, roleName string) (teamRole, error) {
+	var role teamRole
+	err := tx.QueryRow(`
+		SELECT id, name, level FROM roles
+		WHERE name = $1 AND is_active = true
+		AND (project_token IS NULL OR project_token = $2);
+	`, roleName, projectToken).Scan(&role.id, &role.name, &role.level)
+	if err == sql.ErrNoRows {
+		return role, ErrRoleNotFound
+	}
+	return role, err
+}
+
+// assignableRole looks up a role the performer may hand out: never the owner
+// role, and nothing above the performer's own role unless they own the project
+func assignableRole(tx *sql.Tx, projectToken string, roleName string, performedBy string) (teamRole, error) {
+	role, err := getRoleByName(tx, projectToken, roleName)
+	if err != nil {
+		return role, err
+	}
+	if role.name == ownerRoleName {
+		return role, ErrOwnerRole
+	}
+
+	performerRole, err := getPerformerRole(tx, projectToken, performedBy)
+	if err != nil {
+		return role, err
+	}
+
+	if performerRole.name != ownerRoleName && role.level > performerRole.level {
+		return role, ErrRoleAboveOwn
+	}
+
+	return role, nil
+}
+
+// checkMemberBelowPerformer only lets the owner change members whose current
+// role is at or above the performer's own
+func checkMemberBelowPerformer(tx *sql.Tx, projectToken string, member teamMember, performedBy string) error {
+	performerRole, err := getPerformerRole(tx, projectToken, performedBy)
+	if err != nil {
+		return err
+	}
+
+	if performerRole.name != ownerRoleName && member.role.level >= performerRole.level {
+		return ErrMemberAboveOwn
+	}
+	return nil
+}
+
+// getPerformerRole returns the role of the active member making a change.
+// Non-members get ErrRoleAboveOwn, they may not assign anything.
+func getPerformerRole(tx *sql.Tx, projectToken string, performedBy string) (teamRole, error) {
+	var performerRole teamRole
+	err := tx.QueryRow(`
+		SELECT r.id, r.name, r.level
+		FROM projects_team_members ptm
+		JOIN roles r ON r.id = ptm.role_id
+		WHERE ptm.projecttoken = $1 AND ptm.userprivatetoken = $2 AND ptm.is_active = true;
+	`, projectToken, performedBy).Scan(&performerRole.id, &performerRole.name, &performerRole.level)
+	if err == sql.ErrNoRows {
+		return performerRole, ErrRoleAboveOwn
+	}
+	return performerRole, err
+}
+
+func lockMember(tx *sql.Tx, projectToken string, userPrivateToken string) (teamMember, error) {
+	var member teamMember
+	err := tx.QueryRow(`
+		SELECT r.id, r.name, r.level, ptm.is_active
+		FROM projects_team_members ptm
+		JOIN roles r ON r.id = ptm.role_id
+		WHERE ptm.projecttoken = $1 AND ptm.userprivatetoken = $2
+		FOR UPDATE OF ptm;
+	`, projectToken, userPrivateToken).Scan(&member.role.id, &member.role.name, &member.role.level, &member.isActive)
+	if err == sql.ErrNoRows {
+		return member, ErrMemberNotFound
+	}
+	return member, err
+}
+
+// writeAuditLog records a change in rbac_audit_log. userPrivateToken is
+// empty for changes to roles rather than to a member.
+func writeAuditLog(tx *sql.Tx, projectToken string, userPrivateToken string, actionType string, oldRoleID *int, newRoleID *int, performedBy string, reason string) error {
+	var userValue sql.NullString
+	if userPrivateToken != "" {
+		userValue = sql.NullString{String: userPrivateToken, Valid: true}
+	}
+
+	var reasonValue sql.NullString
+	if reason = strings.TrimSpace(reason); reason != "" {
+		reasonValue = sql.NullString{String: reason, Valid: true}
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO rbac_audit_log (projecttoken, userprivatetoken, action_type, old_role_id, new_role_id, performed_by, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`, projectToken, userValue, actionType, oldRoleID, newRoleID, performedBy, reasonValue)
+	return err
+}
@@ -0,0 +1,177 @@
+package lib
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Audience of the tokens accepted by the project-manager API
+const APIAudience = "project-manager-api"
+
+// Secrets shorter than this are rejected, HS256 needs at least 256 bits
+const minSigningKeyLength = 32
+
+var (
+	ErrTokenServiceDisabled = errors.New("no token signing keys configured")
+	ErrUnknownSigningKey    = errors.New("token signed with an unknown key")
+)
+
+// TokenClaims are the claims of tokens issued by the TokenService. The
+// subject is a user public token.
+type TokenClaims struct {
+	jwt.RegisteredClaims
+}
+
+// TokenService issues and verifies HS256 JWTs. Tokens are signed with the
+// active key and carry its ID in the kid header, so keys can be rotated by
+// adding a new active key and removing the old one once its tokens expired.
+type TokenService struct {
+	issuer    string
+	keys      map[string][]byte
+	activeKID string
+}
+
+func NewTokenService(issuer string, keys map[string][]byte, activeKID string) (*TokenService, error) {
+	if _, exists := keys[activeKID]; !exists {
+		return nil, fmt.Errorf("active signing key %q is not in the keyring", activeKID)
+	}
+	for kid, key := range keys {
+		if len(key) < minSigningKeyLength {
+			return nil, fmt.Errorf("signing key %q must be at least %d bytes", kid, minSigningKeyLength)
+		}
+	}
+
+	return &TokenService{issuer: issuer, keys: keys, activeKID: activeKID}, nil
+}
+
+var (
+	tokenService     *TokenService
+	tokenServiceErr  error
+	tokenServiceOnce sync.Once
+)
+
+// GetTokenService returns the service configured by TOKEN_SIGNING_KEYS, a
+// comma separated list of <kid>=<secret>, and TOKEN_ACTIVE_KEY_ID which
+// defaults to the first key. TOKEN_ISSUER defaults to "project-manager".
+func GetTokenService() (*TokenService, error) {
+	tokenServiceOnce.Do(func() {
+		tokenService, tokenServiceErr = loadTokenService()
+		if tokenServiceErr != nil && tokenServiceErr != ErrTokenServiceDisabled {
+			log.Printf("[ERROR] Invalid token service configuration: %v", tokenServiceErr)
+		}
+	})
+	return tokenService, tokenServiceErr
+}
+
+func loadTokenService() (*TokenService, error) {
+	value := os.Getenv("TOKEN_SIGNING_KEYS")
+	if value == "" {
+		return nil, ErrTokenServiceDisabled
+	}
+
+	keys := make(map[string][]byte)
+	var firstKID string
+	for _, entry := range strings.Split(value, ",") {
+		kid, secret, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || kid == "" {
+			return nil, fmt.Errorf("invalid TOKEN_SIGNING_KEYS entry, expected <kid>=<secret>")
+		}
+		if firstKID == "" {
+			firstKID = kid
+		}
+		keys[kid] = []byte(secret)
+	}
+
+	activeKID := os.Getenv("TOKEN_ACTIVE_KEY_ID")
+	if activeKID == "" {
+		activeKID = firstKID
+	}
+
+	issuer := os.Getenv("TOKEN_ISSUER")
+	if issuer == "" {
+		issuer = "project-manager"
+	}
+
+	return NewTokenService(issuer, keys, activeKID)
+}
+
+// Issue signs a token for subject, valid for audience during ttl
+func (s *TokenService) Issue(subject string, audience string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	// A random ID keeps tokens issued within the same second distinct
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        hex.EncodeToString(id),
+		},
+	})
+	token.Header["kid"] = s.activeKID
+
+	signed, err := token.SignedString(s.keys[s.activeKID])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+// Verify checks the signature against the keyring and requires the issuer,
+// audience, subject and expiry
+func (s *TokenService) Verify(tokenString string, audience string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, exists := s.keys[kid]
+		if !exists {
+			return nil, ErrUnknownSigningKey
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return claims, nil
+}
+
+// CreateProjectToken returns a random opaque project identifier. Hex keeps it
+// safe in paths, URLs and git command arguments.
+func CreateProjectToken() (string, error) {
+	token := make([]byte, 20)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
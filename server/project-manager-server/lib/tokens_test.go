@@ -0,0 +1,186 @@
+package lib
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	testOldKey = []byte("old-signing-key-0123456789abcdef0123")
+	testNewKey = []byte("new-signing-key-0123456789abcdef0123")
+)
+
+func newTestTokenService(t *testing.T, issuer string, keys map[string][]byte, activeKID string) *TokenService {
+	t.Helper()
+
+	service, err := NewTokenService(issuer, keys, activeKID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+// signTestToken signs claims a TokenService would issue with an arbitrary
+// method and key
+func signTestToken(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+
+	now := time.Now()
+	token := jwt.NewWithClaims(method, TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "project-manager",
+			Subject:   "user",
+			Audience:  jwt.ClaimStrings{APIAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestNewTokenServiceRejectsInvalidKeyrings(t *testing.T) {
+	if _, err := NewTokenService("project-manager", map[string][]byte{"old": testOldKey}, "new"); err == nil {
+		t.Error("active key missing from the keyring was accepted")
+	}
+	if _, err := NewTokenService("project-manager", map[string][]byte{"old": []byte("short")}, "old"); err == nil {
+		t.Error("signing key shorter than 32 bytes was accepted")
+	}
+}
+
+func TestTokenServiceIssueAndVerify(t *testing.T) {
+	service := newTestTokenService(t, "project-manager", map[string][]byte{"old": testOldKey}, "old")
+
+	signed, expiresAt, err := service.Issue("user", APIAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := service.Verify(signed, APIAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user" || claims.Issuer != "project-manager" || claims.ID == "" {
+		t.Errorf("claims = %+v", claims.RegisteredClaims)
+	}
+	if !claims.ExpiresAt.Time.Equal(expiresAt.Truncate(time.Second)) {
+		t.Errorf("expiry = %v, want %v", claims.ExpiresAt.Time, expiresAt)
+	}
+}
+
+func TestTokenServiceKeyRotation(t *testing.T) {
+	before := newTestTokenService(t, "project-manager", map[string][]byte{"old": testOldKey}, "old")
+	during := newTestTokenService(t, "project-manager", map[string][]byte{"old": testOldKey, "new": testNewKey}, "new")
+	after := newTestTokenService(t, "project-manager", map[string][]byte{"new": testNewKey}, "new")
+
+	oldToken, _, err := before.Issue("user", APIAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, _, err := during.Issue("user", APIAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &TokenClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := parsed.Header["kid"]; kid != "new" {
+		t.Errorf("kid = %v, want the active key new", kid)
+	}
+
+	if _, err := during.Verify(oldToken, APIAudience); err != nil {
+		t.Errorf("token of the previous key rejected while both are configured: %v", err)
+	}
+	if _, err := after.Verify(newToken, APIAudience); err != nil {
+		t.Errorf("token of the active key rejected: %v", err)
+	}
+	if _, err := after.Verify(oldToken, APIAudience); !errors.Is(err, ErrUnknownSigningKey) {
+		t.Errorf("token of a removed key: err = %v, want %v", err, ErrUnknownSigningKey)
+	}
+}
+
+func TestTokenServiceVerifyRejects(t *testing.T) {
+	service := newTestTokenService(t, "project-manager", map[string][]byte{"old": testOldKey}, "old")
+
+	valid, _, err := service.Issue("user", APIAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := service.Issue("user", APIAudience, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer, _, err := newTestTokenService(t, "someone-else", map[string][]byte{"old": testOldKey}, "old").
+		Issue("user", APIAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noSubject, _, err := service.Issue("", APIAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same kid, different secret
+	forged, _, err := newTestTokenService(t, "project-manager", map[string][]byte{"old": testNewKey}, "old").
+		Issue("user", APIAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		audience string
+		want     error
+	}{
+		{"unknown kid", signTestToken(t, jwt.SigningMethodHS256, "missing", testOldKey), APIAudience, ErrUnknownSigningKey},
+		{"wrong signature", forged, APIAudience, jwt.ErrTokenSignatureInvalid},
+		{"wrong audience", valid, "file-server", jwt.ErrTokenInvalidAudience},
+		{"wrong issuer", otherIssuer, APIAudience, jwt.ErrTokenInvalidIssuer},
+		{"expired", expired, APIAudience, jwt.ErrTokenExpired},
+		{"alg none", signTestToken(t, jwt.SigningMethodNone, "old", jwt.UnsafeAllowNoneSignatureType), APIAudience, jwt.ErrTokenSignatureInvalid},
+		{"alg RS256", signTestToken(t, jwt.SigningMethodRS256, "old", rsaKey), APIAudience, jwt.ErrTokenSignatureInvalid},
+		{"tampered payload", tamperPayload(t, valid), APIAudience, jwt.ErrTokenSignatureInvalid},
+		{"no subject", noSubject, APIAudience, nil},
+	}
+
+	for _, test := range tests {
+		claims, err := service.Verify(test.token, test.audience)
+		if err == nil {
+			t.Errorf("%s: token accepted with claims %+v", test.name, claims.RegisteredClaims)
+			continue
+		}
+		if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+// tamperPayload swaps the payload of a signed token for one naming another
+// subject, keeping the original header and signature
+func tamperPayload(t *testing.T, signed string) string {
+	t.Helper()
+
+	parts := strings.Split(signed, ".")
+	other := signTestToken(t, jwt.SigningMethodHS256, "old", testNewKey)
+	parts[1] = strings.Split(other, ".")[1]
+	return strings.Join(parts, ".")
+}
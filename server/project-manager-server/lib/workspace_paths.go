@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidProjectToken  = errors.New("invalid project token")
+	ErrInvalidPath          = errors.New("invalid path")
+	ErrPathOutsideWorkspace = errors.New("path is outside the project workspace")
+)
+
+// Opaque hex tokens and the older JWT-shaped ones. The first character
+// keeps tokens from being read as command line options.
+var projectTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,249}$`)
+
+func ValidateProjectToken(projectToken string) error {
+	if !projectTokenPattern.MatchString(projectToken) || strings.Contains(projectToken, "..") {
+		return ErrInvalidProjectToken
+	}
+	return nil
+}
+
+// WorkspaceRoot returns the workspace folder of a project inside baseDir
+func WorkspaceRoot(baseDir string, projectToken string) (string, error) {
+	if err := ValidateProjectToken(projectToken); err != nil {
+		return "", err
+	}
+
+	absBase, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(absBase, projectToken), nil
+}
+
+// RepositoryPath returns the bare repository of a project inside baseDir
+func RepositoryPath(baseDir string, projectToken string) (string, error) {
+	root, err := WorkspaceRoot(baseDir, projectToken)
+	if err != nil {
+		return "", err
+	}
+	return root + ".git", nil
+}
+
+// ResolveWorkspacePath turns a path relative to the project's workspace into
+// an absolute path inside it. Absolute paths, ".." segments, paths naming the
+// workspace itself and paths leading through symlinks to outside the
+// workspace are rejected.
+func ResolveWorkspacePath(baseDir string, projectToken string, relPath string) (string, error) {
+	root, err := WorkspaceRoot(baseDir, projectToken)
+	if err != nil {
+		return "", err
+	}
+
+	cleaned, err := CleanRelativePath(relPath)
+	if err != nil {
+		return "", err
+	}
+
+	fullPath := filepath.Join(root, filepath.FromSlash(cleaned))
+	if !isWithin(root, fullPath) {
+		return "", ErrPathOutsideWorkspace
+	}
+
+	if err := checkSymlinks(root, fullPath); err != nil {
+		return "", err
+	}
+
+	return fullPath, nil
+}
+
+// CleanRelativePath validates a path relative to a workspace or repository
+// root and returns it cleaned, with forward slashes
+func CleanRelativePath(relPath string) (string, error) {
+	if relPath == "" || strings.ContainsRune(relPath, 0) {
+		return "", ErrInvalidPath
+	}
+
+	// Backslashes are separators for Windows clients, treat them the same
+	// way on every platform
+	slashed := strings.ReplaceAll(relPath, `\`, "/")
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(relPath) || filepath.VolumeName(relPath) != "" {
+		return "", ErrInvalidPath
+	}
+	for _, segment := range strings.Split(slashed, "/") {
+		if segment == ".." {
+			return "", ErrPathOutsideWorkspace
+		}
+	}
+
+	cleaned := path.Clean(slashed)
+	if cleaned == "." {
+		return "", ErrInvalidPath
+	}
+
+	return cleaned, nil
+}
+
+// checkSymlinks resolves the longest existing part of fullPath and makes
+// sure it still lies inside root
+func checkSymlinks(root string, fullPath string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Nothing below a missing root can be a symlink yet
+			return nil
+		}
+		return err
+	}
+
+	existing := fullPath
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing || !isWithin(root, parent) && parent != root {
+			return nil
+		}
+		existing = parent
+	}
+
+	realPath, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		// Dangling symlinks cannot be followed safely
+		return ErrPathOutsideWorkspace
+	}
+
+	if realPath != realRoot && !isWithin(realRoot, realPath) {
+		return ErrPathOutsideWorkspace
+	}
+
+	return nil
+}
+
+func isWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
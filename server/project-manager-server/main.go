@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"log"
+	"net"
 	"os"
+	"os/signal"
 	"project-manager-server/config"
+	"project-manager-server/gitssh"
+	"project-manager-server/services"
+	"syscall"
+	"time"
 
-	// "project-manager-server/lib"
+	"project-manager-server/lib"
 	"project-manager-server/routes"
 
 	"github.com/gofiber/fiber/v3"
@@ -37,6 +45,15 @@ func main() {
 		log.Fatalf("SERVER_PORT environment variable not set")
 	}
 
+	shutdownTimeout := 30 * time.Second
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid SHUTDOWN_TIMEOUT: %v", err)
+		}
+		shutdownTimeout = timeout
+	}
+
 	// Initialize the database connection
 	db, err := config.InitDB()
 	if err != nil {
@@ -44,6 +61,15 @@ func main() {
 	}
 	defer db.Close()
 
+	if _, err := lib.GetTokenService(); err != nil && err != lib.ErrTokenServiceDisabled {
+		log.Fatalf("Failed to load token signing keys: %v", err)
+	}
+
+	tlsConfig, err := config.LoadTLSConfig()
+	if err != nil {
+		log.Fatalf("Failed to load TLS config: %v", err)
+	}
+
 	// Create a Fiber app
 	app := fiber.New(fiber.Config{
 		StreamRequestBody: true,
@@ -61,10 +87,98 @@ func main() {
 
 	routes.InitRoutes(app, db)
 
+	// The SSH git server only runs when SSH_PORT is set
+	var sshServer *gitssh.Server
+	if sshPort := os.Getenv("SSH_PORT"); sshPort != "" {
+		hostKeyPath := os.Getenv("SSH_HOST_KEY_PATH")
+		if hostKeyPath == "" {
+			hostKeyPath = "ssh_host_ed25519_key"
+		}
+
+		sshServer, err = gitssh.NewServer(db, hostKeyPath, os.Getenv("REPOSITORIES_FOLDER_PATH"))
+		if err != nil {
+			log.Fatalf("Failed to create SSH server: %v", err)
+		}
+
+		sshListener, err := net.Listen("tcp", serverHost+":"+sshPort)
+		if err != nil {
+			log.Fatalf("Error starting SSH server: %v", err)
+		}
+
+		log.Printf("SSH git server listening on %s:%s", serverHost, sshPort)
+		go func() {
+			if err := sshServer.Serve(sshListener); err != nil {
+				log.Printf("SSH server stopped: %v", err)
+			}
+		}()
+	}
+
+	// Stop accepting new connections on SIGTERM and let in-flight requests
+	// (git pushes, clones) finish within the drain timeout
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		log.Printf("Shutting down, waiting up to %s for in-flight requests", shutdownTimeout)
+		services.SetDraining()
+	}()
+
+	// Permission checks are cached until a membership or role changes
+	go lib.ListenPermissionChanges(ctx, config.DataSourceName())
+
+	// SSH sessions drain alongside the HTTP requests
+	sshDone := make(chan struct{})
+	go func() {
+		defer close(sshDone)
+		<-ctx.Done()
+		if sshServer == nil {
+			return
+		}
+
+		sshCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := sshServer.Shutdown(sshCtx); err != nil {
+			log.Printf("SSH server shutdown: %v", err)
+		}
+	}()
+
+	// Listen returns as soon as the listener is closed, the drain itself
+	// finishes in the background
+	shutdownDone := make(chan struct{})
+
+	listenConfig := fiber.ListenConfig{
+		GracefulContext: ctx,
+		ShutdownTimeout: shutdownTimeout,
+		OnShutdownError: func(err error) {
+			log.Printf("Graceful shutdown failed: %v", err)
+			close(shutdownDone)
+		},
+		OnShutdownSuccess: func() {
+			close(shutdownDone)
+		},
+	}
+
 	// Start the server
 	log.Printf("Server is attempting to listen on %s\n", serverHost)
-	if err := app.Listen(serverHost+":"+serverPORT); err != nil {
+	if tlsConfig == nil {
+		err = app.Listen(serverHost+":"+serverPORT, listenConfig)
+	} else {
+		var ln net.Listener
+		ln, err = tls.Listen("tcp", serverHost+":"+serverPORT, tlsConfig)
+		if err == nil {
+			log.Println("TLS enabled")
+			err = app.Listener(ln, listenConfig)
+		}
+	}
+	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
 
+	if ctx.Err() != nil {
+		<-shutdownDone
+		<-sshDone
+	}
+
+	log.Println("Server stopped")
 }
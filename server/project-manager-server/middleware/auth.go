@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"database/sql"
+	"strings"
+
+	"project-manager-server/lib"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Key of the *models.AuthenticatedUser stored in the request locals
+const AuthenticatedUserKey = "authenticatedUser"
+
+// SessionAuth rejects requests without a valid session token, personal
+// access token or token service JWT in the Authorization header
+// ("Bearer <token>") and stores the resolved user on the request context
+func SessionAuth(db *sql.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if errMessage := authenticate(c, db); errMessage != "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": errMessage})
+		}
+
+		return c.Next()
+	}
+}
+
+// authenticate resolves the user of the request and stores it on the request
+// context. It returns the reason when the request is not authenticated.
+func authenticate(c fiber.Ctx, db *sql.DB) string {
+	if GetAuthenticatedUser(c) != nil {
+		return ""
+	}
+
+	token := bearerToken(c)
+	if token == "" {
+		return "Missing session token"
+	}
+
+	if lib.IsAccessToken(token) {
+		user := lib.ResolveAccessToken(db, token)
+		if user == nil {
+			return "Invalid, expired or revoked access token"
+		}
+
+		c.Locals(AuthenticatedUserKey, user)
+		return ""
+	}
+
+	// Tokens issued by the token service carry the user public token as
+	// subject. Anything else is looked up as a session.
+	if tokens, err := lib.GetTokenService(); err == nil {
+		if claims, err := tokens.Verify(token, lib.APIAudience); err == nil {
+			userPrivateToken := lib.GetPrivateTokenByPublicToken(claims.Subject, db)
+			if userPrivateToken == "" {
+				return "Unknown token subject"
+			}
+
+			c.Locals(AuthenticatedUserKey, &models.AuthenticatedUser{PrivateToken: userPrivateToken})
+			return ""
+		}
+	}
+
+	userPrivateToken := lib.GetPrivateTokenBySessionToken(token, db)
+	if userPrivateToken == "" {
+		return "Invalid or expired session"
+	}
+
+	c.Locals(AuthenticatedUserKey, &models.AuthenticatedUser{
+		PrivateToken: userPrivateToken,
+		SessionToken: token,
+	})
+
+	return ""
+}
+
+// GetAuthenticatedUser returns the user resolved by SessionAuth, or nil on
+// routes without authentication
+func GetAuthenticatedUser(c fiber.Ctx) *models.AuthenticatedUser {
+	user, _ := c.Locals(AuthenticatedUserKey).(*models.AuthenticatedUser)
+	return user
+}
+
+func bearerToken(c fiber.Ctx) string {
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[7:])
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"strings"
+
+	"project-manager-server/lib"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Realm shown by git and browsers when asking for credentials
+const gitAuthRealm = "project-manager"
+
+// authorizeGit checks the HTTP Basic credentials of git clients. The username
+// is the user's email and the password a session token or personal access
+// token of that user. Fetching needs
+// code:read and pushing code:update on the project of the repository.
+func authorizeGit(c fiber.Ctx, db *sql.DB, repo string) error {
+	email, token, ok := basicCredentials(c)
+	if !ok {
+		return gitChallenge(c)
+	}
+
+	var user *models.AuthenticatedUser
+	if lib.IsAccessToken(token) {
+		user = lib.ResolveAccessTokenCredentials(db, email, token)
+	} else if userPrivateToken := lib.GetPrivateTokenByCredentials(email, token, db); userPrivateToken != "" {
+		user = &models.AuthenticatedUser{
+			PrivateToken: userPrivateToken,
+			SessionToken: token,
+		}
+	}
+	if user == nil {
+		return gitChallenge(c)
+	}
+
+	c.Locals(AuthenticatedUserKey, user)
+
+	action := "read"
+	if gitService(c) == "git-receive-pack" {
+		action = "update"
+	}
+
+	projectToken := strings.TrimSuffix(repo, ".git")
+	if !lib.AccessTokenAllows(user, projectToken, "code", action) ||
+		!lib.CheckUserPermissions(db, user.PrivateToken, projectToken, "code", action) {
+		return c.Status(fiber.StatusForbidden).SendString("You don't have permission to " + action + " this repository")
+	}
+
+	return c.Next()
+}
+
+// gitService returns the service requested either in the info/refs query or
+// in the RPC path
+func gitService(c fiber.Ctx) string {
+	if strings.HasSuffix(c.Path(), "/git-receive-pack") {
+		return "git-receive-pack"
+	}
+	if strings.HasSuffix(c.Path(), "/git-upload-pack") {
+		return "git-upload-pack"
+	}
+	return c.Query("service")
+}
+
+// gitChallenge answers 401 with a Basic challenge, which makes command line
+// git prompt for credentials or ask its credential helper
+func gitChallenge(c fiber.Ctx) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="`+gitAuthRealm+`", charset="UTF-8"`)
+	return c.Status(fiber.StatusUnauthorized).SendString("Authentication required")
+}
+
+func basicCredentials(c fiber.Ctx) (string, string, bool) {
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) < 6 || !strings.EqualFold(auth[:6], "Basic ") {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(auth[6:]))
+	if err != nil {
+		return "", "", false
+	}
+
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found || username == "" || password == "" {
+		return "", "", false
+	}
+
+	return username, password, true
+}
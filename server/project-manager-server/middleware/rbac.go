@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	"project-manager-server/lib"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RoutePermission declares who may call a route
+type RoutePermission struct {
+	// No authentication here, the handler authenticates on its own or the
+	// route is meant to be open (health probes)
+	Public bool
+	// Git smart HTTP route, authenticated with HTTP Basic credentials and
+	// authorized against the project of the :repo parameter
+	Git bool
+	// Resource and action names from the resources and actions tables that
+	// the user needs on the project of the request. Empty for routes that
+	// only need an authenticated user.
+	Resource string
+	Action   string
+}
+
+func Public() RoutePermission {
+	return RoutePermission{Public: true}
+}
+
+func Authenticated() RoutePermission {
+	return RoutePermission{}
+}
+
+func Require(resource string, action string) RoutePermission {
+	return RoutePermission{Resource: resource, Action: action}
+}
+
+// GitSmartHTTP requires code:read to fetch and code:update to push
+func GitSmartHTTP() RoutePermission {
+	return RoutePermission{Git: true}
+}
+
+// PermissionTable maps "<METHOD> <route path>" to the permission the route
+// requires, e.g. "GET /api/projects/repo-file"
+type PermissionTable map[string]RoutePermission
+
+// ProjectTokenKey is the c.Locals key of the project token of the request
+const ProjectTokenKey = "projectToken"
+
+type routeMatcher struct {
+	method     string
+	segments   []string
+	permission RoutePermission
+}
+
+// RBACMiddleware enforces the permission table on every request. Requests to
+// routes without a declaration are rejected.
+func RBACMiddleware(db *sql.DB, table PermissionTable) fiber.Handler {
+	matchers := buildMatchers(table)
+
+	return func(c fiber.Ctx) error {
+		permission, params, found := matchRoute(matchers, c.Method(), c.Path())
+		if !found {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access to this route is not allowed"})
+		}
+
+		if permission.Public {
+			return c.Next()
+		}
+
+		if permission.Git {
+			return authorizeGit(c, db, params["repo"])
+		}
+
+		if errMessage := authenticate(c, db); errMessage != "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": errMessage})
+		}
+
+		// Handlers act on this token only, so the one checked here cannot
+		// differ from the one a handler would read from the body
+		projectToken, ok := requestProjectToken(c)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "projectToken differs between the query and the body"})
+		}
+		c.Locals(ProjectTokenKey, projectToken)
+
+		if permission.Resource == "" {
+			return c.Next()
+		}
+
+		if projectToken == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "projectToken is required"})
+		}
+
+		user := GetAuthenticatedUser(c)
+		if !lib.AccessTokenAllows(user, projectToken, permission.Resource, permission.Action) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "The access token does not grant " + permission.Resource + ":" + permission.Action + " on this project",
+			})
+		}
+
+		if !lib.CheckUserPermissions(db, user.PrivateToken, projectToken, permission.Resource, permission.Action) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You don't have permission to " + permission.Action + " " + permission.Resource,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// LogUnprotectedRoutes lists routes that are public or missing from the
+// permission table, the latter reject every request
+func LogUnprotectedRoutes(app *fiber.App, table PermissionTable) {
+	var public, undeclared []string
+
+	for _, route := range app.GetRoutes(true) {
+		// HEAD routes are added automatically for every GET route
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+
+		key := route.Method + " " + route.Path
+		permission, found := table[key]
+		switch {
+		case !found:
+			undeclared = append(undeclared, key)
+		case permission.Public:
+			public = append(public, key)
+		}
+	}
+
+	sort.Strings(public)
+	sort.Strings(undeclared)
+
+	for _, route := range public {
+		log.Printf("[INFO] Public route without permission check: %s", route)
+	}
+	for _, route := range undeclared {
+		log.Printf("[WARN] Route without permission declaration, all requests are rejected: %s", route)
+	}
+}
+
+// buildMatchers orders the table so that routes overlapping on a segment try
+// the static one before the ":name" parameter, e.g. /tags/latest before
+// /tags/:name, and the order never depends on map iteration
+func buildMatchers(table PermissionTable) []routeMatcher {
+	routes := make([]string, 0, len(table))
+	for route := range table {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	matchers := make([]routeMatcher, 0, len(routes))
+	for _, route := range routes {
+		method, path, found := strings.Cut(route, " ")
+		if !found {
+			log.Fatalf("Invalid permission table entry %q, expected \"<METHOD> <path>\"", route)
+		}
+
+		matchers = append(matchers, routeMatcher{
+			method:     method,
+			segments:   splitPath(path),
+			permission: table[route],
+		})
+	}
+
+	sort.SliceStable(matchers, func(i, j int) bool {
+		return moreSpecific(matchers[i].segments, matchers[j].segments)
+	})
+	return matchers
+}
+
+// moreSpecific reports whether pattern a has a static segment where b has a
+// parameter, comparing from the first segment on
+func moreSpecific(a []string, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		aParam, bParam := strings.HasPrefix(a[i], ":"), strings.HasPrefix(b[i], ":")
+		if aParam != bParam {
+			return bParam
+		}
+	}
+	return len(a) < len(b)
+}
+
+// matchRoute returns the permission of the first declared route matching the
+// request, along with the values of its ":name" parameters
+func matchRoute(matchers []routeMatcher, method string, path string) (RoutePermission, map[string]string, bool) {
+	if method == fiber.MethodHead {
+		method = fiber.MethodGet
+	}
+
+	segments := splitPath(path)
+	for _, matcher := range matchers {
+		if matcher.method != method {
+			continue
+		}
+		if params, ok := matchSegments(matcher.segments, segments); ok {
+			return matcher.permission, params, true
+		}
+	}
+
+	return RoutePermission{}, nil, false
+}
+
+func matchSegments(pattern []string, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, part := range pattern {
+		if strings.HasPrefix(part, ":") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[part[1:]] = segments[i]
+			continue
+		}
+		if part != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// GetProjectToken returns the project token RBACMiddleware resolved for the
+// request, empty when the request has none
+func GetProjectToken(c fiber.Ctx) string {
+	projectToken, _ := c.Locals(ProjectTokenKey).(string)
+	return projectToken
+}
+
+// requestProjectToken reads the project token from the query string and from
+// the JSON body. It reports false when both are set and differ.
+func requestProjectToken(c fiber.Ctx) (string, bool) {
+	queryToken := c.Query("projectToken")
+
+	var body struct {
+		ProjectToken string `json:"projectToken"`
+	}
+	if c.Is("json") {
+		json.Unmarshal(c.Body(), &body)
+	}
+
+	switch {
+	case queryToken == "":
+		return body.ProjectToken, true
+	case body.ProjectToken == "" || body.ProjectToken == queryToken:
+		return queryToken, true
+	}
+	return "", false
+}
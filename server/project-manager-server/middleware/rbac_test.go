@@ -0,0 +1,61 @@
+package middleware
+
+import "testing"
+
+func TestMatchRoutePrefersStaticSegments(t *testing.T) {
+	table := PermissionTable{
+		"GET /api/projects/tags/:name":       Require("code", "read"),
+		"GET /api/projects/tags/latest":      Public(),
+		"GET /api/:scope/tags/latest":        Require("project", "manage"),
+		"DELETE /api/projects/tags/latest":   Require("code", "delete"),
+		"GET /api/projects/:resource/:name":  Require("project", "read"),
+		"GET /api/projects/releases/:id":     Require("code", "update"),
+		"GET /api/projects/releases/:id/raw": Authenticated(),
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   RoutePermission
+		params map[string]string
+	}{
+		{"GET", "/api/projects/tags/latest", Public(), map[string]string{}},
+		{"HEAD", "/api/projects/tags/latest", Public(), map[string]string{}},
+		{"GET", "/api/projects/tags/v1.0", Require("code", "read"), map[string]string{"name": "v1.0"}},
+		{"GET", "/api/other/tags/latest", Require("project", "manage"), map[string]string{"scope": "other"}},
+		{"DELETE", "/api/projects/tags/latest", Require("code", "delete"), map[string]string{}},
+		{"GET", "/api/projects/releases/7", Require("code", "update"), map[string]string{"id": "7"}},
+		{"GET", "/api/projects/branches/main", Require("project", "read"), map[string]string{"resource": "branches", "name": "main"}},
+		{"GET", "/api/projects/releases/7/raw", Authenticated(), map[string]string{"id": "7"}},
+	}
+
+	// Map iteration changes between runs, build the matchers a few times
+	for run := 0; run < 20; run++ {
+		matchers := buildMatchers(table)
+
+		for _, test := range tests {
+			got, params, found := matchRoute(matchers, test.method, test.path)
+			if !found {
+				t.Fatalf("%s %s did not match any route", test.method, test.path)
+			}
+			if got != test.want {
+				t.Fatalf("%s %s matched %+v, want %+v", test.method, test.path, got, test.want)
+			}
+			if len(params) != len(test.params) {
+				t.Fatalf("%s %s params = %v, want %v", test.method, test.path, params, test.params)
+			}
+			for name, value := range test.params {
+				if params[name] != value {
+					t.Fatalf("%s %s params = %v, want %v", test.method, test.path, params, test.params)
+				}
+			}
+		}
+	}
+
+	if _, _, found := matchRoute(buildMatchers(table), "POST", "/api/projects/tags/latest"); found {
+		t.Error("POST matched a route declared for other methods only")
+	}
+	if _, _, found := matchRoute(buildMatchers(table), "GET", "/api/projects/tags//"); found {
+		t.Error("empty parameter matched")
+	}
+}
@@ -9,7 +9,8 @@ import (
 
 // XSSConfig defines the config for XSS middleware
 type XSSConfig struct {
-	// Skip these paths from XSS sanitization
+	// Skip these paths from XSS sanitization, segments starting with ":"
+	// match any value, e.g. /api/projects/releases/:releaseId
 	SkipPaths []string
 	// Use strict policy (default: true)
 	StrictPolicy bool
@@ -36,10 +37,16 @@ func XSSMiddleware(config ...XSSConfig) fiber.Handler {
 		policy = bluemonday.UGCPolicy()
 	}
 
+	skipPatterns := make([][]string, len(cfg.SkipPaths))
+	for i, path := range cfg.SkipPaths {
+		skipPatterns[i] = splitPath(path)
+	}
+
 	return func(c fiber.Ctx) error {
 		// Skip if path is in SkipPaths
-		for _, path := range cfg.SkipPaths {
-			if c.Path() == path {
+		segments := splitPath(c.Path())
+		for _, pattern := range skipPatterns {
+			if _, ok := matchSegments(pattern, segments); ok {
 				return c.Next()
 			}
 		}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type AccessToken struct {
+	ID           int        `json:"id"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	ProjectToken *string    `json:"projectToken"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	LastUsedAt   *time.Time `json:"lastUsedAt"`
+	RevokedAt    *time.Time `json:"revokedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+type CreateAccessTokenRequest struct {
+	Name         string    `json:"name"`
+	Scopes       []string  `json:"scopes"`
+	ProjectToken string    `json:"projectToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
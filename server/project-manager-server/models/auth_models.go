@@ -0,0 +1,24 @@
+package models
+
+// AuthenticatedUser is the identity resolved by the auth middleware and
+// stored on the request context
+type AuthenticatedUser struct {
+	PrivateToken string
+	SessionToken string
+
+	// Set when the request authenticated with a personal access token
+	// instead of a session
+	AccessTokenID int
+	Scopes        []string
+	// Project the access token is restricted to, empty for all projects
+	ProjectToken string
+}
+
+type PermissionCacheStats struct {
+	Entries       int     `json:"entries"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRatio      float64 `json:"hitRatio"`
+	Invalidations uint64  `json:"invalidations"`
+	TTLSeconds    float64 `json:"ttlSeconds"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// BlameUser is the platform user behind a commit author email
+type BlameUser struct {
+	UserPublicToken string `json:"userPublicToken"`
+	UserName        string `json:"userName"`
+}
+
+// BlameRange is a run of consecutive lines last changed by the same commit.
+// Path is the file's path in that commit, which differs after renames.
+type BlameRange struct {
+	Commit    string          `json:"commit"`
+	StartLine int             `json:"startLine"`
+	EndLine   int             `json:"endLine"`
+	Path      string          `json:"path"`
+	Author    CommitSignature `json:"author"`
+	User      *BlameUser      `json:"user"`
+	Summary   string          `json:"summary"`
+	Lines     []string        `json:"lines"`
+}
+
+type Blame struct {
+	Commit     string       `json:"commit"`
+	Path       string       `json:"path"`
+	TotalLines int          `json:"totalLines"`
+	Ranges     []BlameRange `json:"ranges"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Branch is a branch of a hosted repository. Ahead and Behind count the
+// commits relative to the default branch.
+type Branch struct {
+	Name      string    `json:"name"`
+	Commit    string    `json:"commit"`
+	Subject   string    `json:"subject"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	IsDefault bool      `json:"isDefault"`
+	Ahead     int       `json:"ahead"`
+	Behind    int       `json:"behind"`
+}
+
+type CreateBranchRequest struct {
+	ProjectToken string `json:"projectToken"`
+	Name         string `json:"name"`
+	From         string `json:"from"`
+}
+
+type RenameBranchRequest struct {
+	ProjectToken string `json:"projectToken"`
+	Name         string `json:"name"`
+	NewName      string `json:"newName"`
+}
+
+type DeleteBranchRequest struct {
+	ProjectToken string `json:"projectToken"`
+	Name         string `json:"name"`
+}
+
+type DefaultBranchRequest struct {
+	ProjectToken string `json:"projectToken"`
+	Name         string `json:"name"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// Actions of a FileChange
+const (
+	FileChangeCreate = "create"
+	FileChangeUpdate = "update"
+	FileChangeDelete = "delete"
+	FileChangeRename = "rename"
+)
+
+// FileChange is one change of a commit. Create fails when the path exists,
+// update writes the file whether it exists or not, delete removes a file or
+// a whole folder and rename moves a file or folder from PreviousPath to Path.
+type FileChange struct {
+	Action       string `json:"action"`
+	Path         string `json:"path"`
+	PreviousPath string `json:"previousPath"`
+	Content      string `json:"content"`
+}
+
+type CommitRequest struct {
+	ProjectToken   string       `json:"projectToken"`
+	Branch         string       `json:"branch"`
+	ExpectedParent string       `json:"expectedParent"`
+	Message        string       `json:"message"`
+	Changes        []FileChange `json:"changes"`
+}
+
+type CommitAuthor struct {
+	Name  string
+	Email string
+}
+
+type CommitResult struct {
+	Commit string `json:"commit"`
+	Parent string `json:"parent"`
+	Branch string `json:"branch"`
+}
+
+type CommitSignature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type Commit struct {
+	SHA       string          `json:"sha"`
+	Parents   []string        `json:"parents"`
+	Author    CommitSignature `json:"author"`
+	Committer CommitSignature `json:"committer"`
+	Message   string          `json:"message"`
+}
+
+// CommitLogQuery filters the history of Ref. Path follows renames when it
+// names a file. Cursor continues a previous page.
+type CommitLogQuery struct {
+	Ref    string
+	Path   string
+	Author string
+	Since  time.Time
+	Until  time.Time
+	Cursor string
+	Limit  int
+}
+
+type CommitLogPage struct {
+	Commits    []Commit `json:"commits"`
+	NextCursor string   `json:"nextCursor"`
+}
+
+// ChangedFile is a file changed by a commit. Status is one of "added",
+// "modified", "deleted", "renamed", "copied" or "type-changed", PreviousPath
+// is set for renames and copies.
+type ChangedFile struct {
+	Path         string `json:"path"`
+	PreviousPath string `json:"previousPath,omitempty"`
+	Status       string `json:"status"`
+	Additions    int    `json:"additions"`
+	Deletions    int    `json:"deletions"`
+	Binary       bool   `json:"binary"`
+}
+
+type CommitDetail struct {
+	Commit
+	Files     []ChangedFile `json:"files"`
+	Additions int           `json:"additions"`
+	Deletions int           `json:"deletions"`
+}
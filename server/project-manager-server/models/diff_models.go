@@ -0,0 +1,52 @@
+package models
+
+// Values of DiffOptions.IgnoreWhitespace
+const (
+	IgnoreWhitespaceNone   = ""
+	IgnoreWhitespaceAll    = "all"
+	IgnoreWhitespaceChange = "change"
+	IgnoreWhitespaceEOL    = "eol"
+)
+
+type DiffOptions struct {
+	IgnoreWhitespace string
+	ContextLines     int
+	MaxFiles         int
+	MaxBytes         int
+}
+
+// DiffLine is a line of a hunk. Type is "context", "addition" or
+// "deletion", the line numbers are 0 on the side the line is missing from.
+type DiffLine struct {
+	Type      string `json:"type"`
+	Content   string `json:"content"`
+	OldLine   int    `json:"oldLine,omitempty"`
+	NewLine   int    `json:"newLine,omitempty"`
+	NoNewline bool   `json:"noNewline,omitempty"`
+}
+
+type DiffHunk struct {
+	Header   string     `json:"header"`
+	OldStart int        `json:"oldStart"`
+	OldLines int        `json:"oldLines"`
+	NewStart int        `json:"newStart"`
+	NewLines int        `json:"newLines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// DiffFile is a changed file, Truncated is set when its patch was left out
+// because the diff hit a limit
+type DiffFile struct {
+	ChangedFile
+	Hunks     []DiffHunk `json:"hunks"`
+	Truncated bool       `json:"truncated"`
+}
+
+type Diff struct {
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Files      []DiffFile `json:"files"`
+	TotalFiles int        `json:"totalFiles"`
+	Truncated  bool       `json:"truncated"`
+	Patch      string     `json:"patch"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// Sources of a permission grant
+const (
+	GrantSourceRole      = "role"      // granted to the member's role
+	GrantSourceInherited = "inherited" // granted to a role the member's role inherits from
+	GrantSourceOwner     = "owner"     // PROJECT_OWNER holds every permission
+	GrantSourceGuest     = "guest"     // withheld, GUEST members only get read actions
+	GrantSourceScope     = "scope"     // withheld, not covered by the access token's scopes
+)
+
+type PermissionGrant struct {
+	Resource   string `json:"resource"`
+	Action     string `json:"action"`
+	Permission string `json:"permission"`
+	Source     string `json:"source"`
+	// Role the permission was granted to
+	Role string `json:"role,omitempty"`
+}
+
+type EffectivePermissions struct {
+	ProjectToken    string `json:"projectToken"`
+	IsMember        bool   `json:"isMember"`
+	Role            string `json:"role,omitempty"`
+	RoleDisplayName string `json:"roleDisplayName,omitempty"`
+	IsOwner         bool   `json:"isOwner"`
+	// Every (resource, action) pair the member is allowed
+	Grants []PermissionGrant `json:"grants"`
+	// Pairs granted to the member's roles that a shortcut takes away
+	Withheld []PermissionGrant `json:"withheld"`
+}
@@ -83,6 +83,8 @@ type FileNode struct {
 type SaveFileRequest struct {
 	ProjectToken     string `json:"projectToken"`
 	UserSessionToken string `json:"userSessionToken"`
+	Branch           string `json:"branch"`
+	Message          string `json:"message"`
 	Path             string `json:"path"`
 	Content          string `json:"content"`
 }
@@ -90,17 +92,23 @@ type SaveFileRequest struct {
 type CreateNewDirectoryRequest struct {
 	ProjectToken     string `json:"projectToken"`
 	UserSessionToken string `json:"userSessionToken"`
+	Branch           string `json:"branch"`
+	Message          string `json:"message"`
 	Path             string `json:"path"`
 }
 
 type CreateNewFileRequest struct {
 	ProjectToken     string `json:"projectToken"`
 	UserSessionToken string `json:"userSessionToken"`
+	Branch           string `json:"branch"`
+	Message          string `json:"message"`
 	Path             string `json:"path"`
 }
 
 type DeleteFileRequest struct {
 	ProjectToken     string `json:"projectToken"`
 	UserSessionToken string `json:"userSessionToken"`
+	Branch           string `json:"branch"`
+	Message          string `json:"message"`
 	Path             string `json:"path"`
 }
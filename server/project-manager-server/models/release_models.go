@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+const (
+	ReleaseDraft     = "draft"
+	ReleasePublished = "published"
+)
+
+type Release struct {
+	ID          int               `json:"id"`
+	TagName     string            `json:"tagName"`
+	Name        string            `json:"name"`
+	Notes       string            `json:"notes"`
+	Status      string            `json:"status"`
+	CreatedBy   string            `json:"createdBy"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	PublishedAt *time.Time        `json:"publishedAt"`
+	Artifacts   []ReleaseArtifact `json:"artifacts"`
+}
+
+type ReleaseArtifact struct {
+	ID          int       `json:"id"`
+	FileName    string    `json:"fileName"`
+	URL         string    `json:"url"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	ContentType string    `json:"contentType"`
+	CreatedAt   time.Time `json:"createdAt"`
+	StoragePath string    `json:"-"`
+}
+
+type CreateReleaseRequest struct {
+	ProjectToken string `json:"projectToken"`
+	TagName      string `json:"tagName"`
+	Name         string `json:"name"`
+	Notes        string `json:"notes"`
+	Status       string `json:"status"`
+}
+
+type UpdateReleaseRequest struct {
+	ProjectToken string  `json:"projectToken"`
+	Name         *string `json:"name"`
+	Notes        *string `json:"notes"`
+	Status       *string `json:"status"`
+}
+
+type DeleteReleaseRequest struct {
+	ProjectToken string `json:"projectToken"`
+}
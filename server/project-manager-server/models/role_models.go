@@ -0,0 +1,34 @@
+package models
+
+type Role struct {
+	ID           int     `json:"id"`
+	Name         string  `json:"name"`
+	DisplayName  string  `json:"displayName"`
+	Description  string  `json:"description"`
+	Level        int     `json:"level"`
+	IsSystemRole bool    `json:"isSystemRole"`
+	ProjectToken *string `json:"projectToken"` // nil for global roles
+	// Names of the roles this role inherits from
+	Parents []string `json:"parents"`
+	// Directly granted permissions as "resource:action"
+	Permissions []string `json:"permissions"`
+}
+
+type RoleRequest struct {
+	ProjectToken string   `json:"projectToken"`
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"displayName"`
+	Description  string   `json:"description"`
+	Level        int      `json:"level"`
+	Parents      []string `json:"parents"`
+	Permissions  []string `json:"permissions"`
+	Reason       string   `json:"reason"`
+}
+
+type CloneRoleRequest struct {
+	ProjectToken string `json:"projectToken"`
+	SourceRole   string `json:"sourceRole"`
+	Name         string `json:"name"`
+	DisplayName  string `json:"displayName"`
+	Reason       string `json:"reason"`
+}
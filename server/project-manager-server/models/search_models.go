@@ -0,0 +1,41 @@
+package models
+
+// Values of SearchQuery.Mode
+const (
+	SearchLiteral = "literal"
+	SearchRegex   = "regex"
+)
+
+// SearchQuery searches the files of Ref. Include and Exclude are glob
+// patterns, patterns without a slash match file names in every folder.
+type SearchQuery struct {
+	Ref          string
+	Query        string
+	Mode         string
+	IgnoreCase   bool
+	Include      []string
+	Exclude      []string
+	ContextLines int
+	Cursor       string
+	Limit        int
+}
+
+// SearchMatch is a matching line, Column is the byte offset of the first
+// match in it, starting at 1
+type SearchMatch struct {
+	Path    string   `json:"path"`
+	Line    int      `json:"line"`
+	Column  int      `json:"column"`
+	Content string   `json:"content"`
+	Before  []string `json:"before"`
+	After   []string `json:"after"`
+}
+
+// SearchPage is one page of matches. TimedOut is set when the search was
+// stopped before the page was complete.
+type SearchPage struct {
+	Commit     string        `json:"commit"`
+	Matches    []SearchMatch `json:"matches"`
+	NextCursor string        `json:"nextCursor"`
+	TimedOut   bool          `json:"timedOut"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type SSHKey struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	PublicKey   string     `json:"publicKey"`
+	Fingerprint string     `json:"fingerprint"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type AddSSHKeyRequest struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+}
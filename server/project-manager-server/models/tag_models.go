@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Tag is a tag of a hosted repository. Message, Tagger and TaggedAt are only
+// set for annotated tags.
+type Tag struct {
+	Name      string     `json:"name"`
+	Commit    string     `json:"commit"`
+	Annotated bool       `json:"annotated"`
+	Message   string     `json:"message,omitempty"`
+	Tagger    string     `json:"tagger,omitempty"`
+	TaggedAt  *time.Time `json:"taggedAt,omitempty"`
+}
+
+// CreateTagRequest creates an annotated tag when Message is set, and a
+// lightweight one otherwise
+type CreateTagRequest struct {
+	ProjectToken string `json:"projectToken"`
+	Name         string `json:"name"`
+	From         string `json:"from"`
+	Message      string `json:"message"`
+}
+
+type DeleteTagRequest struct {
+	ProjectToken string `json:"projectToken"`
+	Name         string `json:"name"`
+}
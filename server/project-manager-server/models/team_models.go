@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type ProjectMember struct {
+	UserPublicToken string    `json:"userPublicToken"`
+	UserName        string    `json:"userName"`
+	UserEmail       string    `json:"userEmail"`
+	Role            string    `json:"role"`
+	RoleDisplayName string    `json:"roleDisplayName"`
+	IsActive        bool      `json:"isActive"`
+	AssignedAt      time.Time `json:"assignedAt"`
+}
+
+type InviteMemberRequest struct {
+	ProjectToken string `json:"projectToken"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	Reason       string `json:"reason"`
+}
+
+type ChangeMemberRoleRequest struct {
+	ProjectToken    string `json:"projectToken"`
+	UserPublicToken string `json:"userPublicToken"`
+	Role            string `json:"role"`
+	Reason          string `json:"reason"`
+}
+
+// Used to deactivate and reactivate members
+type MemberStatusRequest struct {
+	ProjectToken    string `json:"projectToken"`
+	UserPublicToken string `json:"userPublicToken"`
+	Reason          string `json:"reason"`
+}
+
+type TransferOwnershipRequest struct {
+	ProjectToken    string `json:"projectToken"`
+	UserPublicToken string `json:"userPublicToken"`
+	// Role the previous owner keeps, PROJECT_MANAGER when empty
+	PreviousOwnerRole string `json:"previousOwnerRole"`
+	Reason            string `json:"reason"`
+}
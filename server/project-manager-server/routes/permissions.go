@@ -0,0 +1,85 @@
+package routes
+
+import "project-manager-server/middleware"
+
+// Permissions declares the access rule of every route. Requests to routes
+// missing here are rejected, so new routes must be added to this table.
+var Permissions = middleware.PermissionTable{
+	// Health
+	"GET /health":       middleware.Public(),
+	"GET /health/live":  middleware.Public(),
+	"GET /health/ready": middleware.Public(),
+
+	// Permissions
+	"GET /api/permissions/cache-stats":      middleware.Authenticated(),
+	"GET /api/permissions/effective":        middleware.Authenticated(),
+	"GET /api/permissions/effective/member": middleware.Require("role", "manage"),
+
+	// Projects codebase
+	"GET /api/projects/repo-tree":         middleware.Require("code", "read"),
+	"GET /api/projects/repo-file":         middleware.Require("code", "read"),
+	"POST /api/projects/save-file":        middleware.Require("code", "update"),
+	"POST /api/projects/new-folder":       middleware.Require("code", "create"),
+	"POST /api/projects/new-file":         middleware.Require("code", "create"),
+	"DELETE /api/projects/delete-file":    middleware.Require("code", "delete"),
+	"POST /api/projects/commits":          middleware.Require("code", "update"),
+	"GET /api/projects/commits":           middleware.Require("code", "read"),
+	"GET /api/projects/commits/:sha":      middleware.Require("code", "read"),
+	"GET /api/projects/commits/:sha/diff": middleware.Require("code", "read"),
+	"GET /api/projects/diff":              middleware.Require("code", "read"),
+	"GET /api/projects/blame":             middleware.Require("code", "read"),
+	"GET /api/projects/search":            middleware.Require("code", "read"),
+	"GET /api/projects/branches":          middleware.Require("code", "read"),
+	"POST /api/projects/branches":         middleware.Require("code", "create"),
+	"PUT /api/projects/branches/rename":   middleware.Require("code", "update"),
+	"DELETE /api/projects/branches":       middleware.Require("code", "delete"),
+	"PUT /api/projects/branches/default":  middleware.Require("project", "manage"),
+	"GET /api/projects/tags":              middleware.Require("code", "read"),
+	"POST /api/projects/tags":             middleware.Require("code", "create"),
+	"DELETE /api/projects/tags":           middleware.Require("code", "delete"),
+
+	// Releases, drafts are only listed for users who can update code
+	"GET /api/projects/releases":                                     middleware.Require("code", "read"),
+	"POST /api/projects/releases":                                    middleware.Require("code", "create"),
+	"PUT /api/projects/releases/:releaseId":                          middleware.Require("code", "update"),
+	"DELETE /api/projects/releases/:releaseId":                       middleware.Require("code", "delete"),
+	"POST /api/projects/releases/:releaseId/artifacts":               middleware.Require("code", "update"),
+	"GET /api/projects/releases/:releaseId/artifacts/:artifactId":    middleware.Require("code", "read"),
+	"DELETE /api/projects/releases/:releaseId/artifacts/:artifactId": middleware.Require("code", "update"),
+
+	// Project team
+	"GET /api/projects/team":                     middleware.Require("project", "read"),
+	"POST /api/projects/team/invite":             middleware.Require("role", "assign"),
+	"PUT /api/projects/team/role":                middleware.Require("role", "assign"),
+	"POST /api/projects/team/deactivate":         middleware.Require("role", "assign"),
+	"POST /api/projects/team/reactivate":         middleware.Require("role", "assign"),
+	"POST /api/projects/team/transfer-ownership": middleware.Require("role", "assign"),
+
+	// Project roles
+	"GET /api/projects/roles":         middleware.Require("project", "read"),
+	"POST /api/projects/roles":        middleware.Require("role", "manage"),
+	"POST /api/projects/roles/clone":  middleware.Require("role", "manage"),
+	"PUT /api/projects/roles/:roleId": middleware.Require("role", "manage"),
+
+	// Auth
+	"POST /api/auth/token": middleware.Authenticated(),
+
+	// Access tokens
+	"GET /api/access-tokens":        middleware.Authenticated(),
+	"POST /api/access-tokens":       middleware.Authenticated(),
+	"DELETE /api/access-tokens/:id": middleware.Authenticated(),
+
+	// SSH keys
+	"GET /api/ssh-keys":        middleware.Authenticated(),
+	"POST /api/ssh-keys":       middleware.Authenticated(),
+	"DELETE /api/ssh-keys/:id": middleware.Authenticated(),
+
+	// Repositories
+	"POST /api/repositories/generate-repository": middleware.Require("code", "create"),
+	"POST /api/repositories/create":              middleware.Require("code", "create"),
+
+	// Git smart HTTP, authenticated with email and token as Basic credentials
+	"GET /api/repositories/:repo/info/refs":         middleware.GitSmartHTTP(),
+	"POST /api/repositories/:repo/git-upload-pack":  middleware.GitSmartHTTP(),
+	"POST /api/repositories/:repo/git-receive-pack": middleware.GitSmartHTTP(),
+}
@@ -10,17 +10,45 @@ import (
 )
 
 func InitRoutes(app *fiber.App, db *sql.DB) {
-	app.Use(middleware.XSSMiddleware(middleware.XSSConfig{
-		SkipPaths: []string{
-			"/api/projects/save-file",
-		},
-		StrictPolicy: false,
-	}))
+	app.Use(middleware.XSSMiddleware(xssConfig))
+
+	app.Use(middleware.RBACMiddleware(db, Permissions))
 
 	///////////////////////////////////////////////////////////////
-	// 						PROJECTS CODEBASE				     //
+	// 						HEALTH								 //
 	///////////////////////////////////////////////////////////////
 
+	app.Get("/health", func(c fiber.Ctx) error {
+		return services.Liveness(c)
+	})
+
+	app.Get("/health/live", func(c fiber.Ctx) error {
+		return services.Liveness(c)
+	})
+
+	app.Get("/health/ready", func(c fiber.Ctx) error {
+		return services.Readiness(c, db)
+	})
+
+	///////////////////////////////////////////////////////////////
+	// 						PERMISSIONS							 //
+	///////////////////////////////////////////////////////////////
+
+	app.Get("/api/permissions/cache-stats", func(c fiber.Ctx) error {
+		return services.GetPermissionCacheStats(c)
+	})
+
+	app.Get("/api/permissions/effective", func(c fiber.Ctx) error {
+		return services.GetEffectivePermissions(c, db)
+	})
+
+	app.Get("/api/permissions/effective/member", func(c fiber.Ctx) error {
+		return services.GetMemberEffectivePermissions(c, db)
+	})
+
+	///////////////////////////////////////////////////////////////
+	// 						PROJECTS CODEBASE				     //
+	///////////////////////////////////////////////////////////////
 
 	app.Get("/api/projects/repo-tree", func(c fiber.Ctx) error {
 		return services.GetRepositoryTree(c, db)
@@ -46,6 +74,186 @@ func InitRoutes(app *fiber.App, db *sql.DB) {
 		return services.DeleteFile(c, db)
 	})
 
+	app.Post("/api/projects/commits", func(c fiber.Ctx) error {
+		return services.CommitChanges(c, db)
+	})
+
+	app.Get("/api/projects/commits", func(c fiber.Ctx) error {
+		return services.ListCommits(c, db)
+	})
+
+	app.Get("/api/projects/commits/:sha", func(c fiber.Ctx) error {
+		return services.GetCommit(c, db)
+	})
+
+	app.Get("/api/projects/commits/:sha/diff", func(c fiber.Ctx) error {
+		return services.GetCommitDiff(c, db)
+	})
+
+	app.Get("/api/projects/diff", func(c fiber.Ctx) error {
+		return services.CompareRefs(c, db)
+	})
+
+	app.Get("/api/projects/blame", func(c fiber.Ctx) error {
+		return services.GetBlame(c, db)
+	})
+
+	app.Get("/api/projects/search", func(c fiber.Ctx) error {
+		return services.SearchCode(c, db)
+	})
+
+	app.Get("/api/projects/branches", func(c fiber.Ctx) error {
+		return services.ListBranches(c, db)
+	})
+
+	app.Post("/api/projects/branches", func(c fiber.Ctx) error {
+		return services.CreateBranch(c, db)
+	})
+
+	app.Put("/api/projects/branches/rename", func(c fiber.Ctx) error {
+		return services.RenameBranch(c, db)
+	})
+
+	app.Delete("/api/projects/branches", func(c fiber.Ctx) error {
+		return services.DeleteBranch(c, db)
+	})
+
+	app.Put("/api/projects/branches/default", func(c fiber.Ctx) error {
+		return services.SetDefaultBranch(c, db)
+	})
+
+	app.Get("/api/projects/tags", func(c fiber.Ctx) error {
+		return services.ListTags(c, db)
+	})
+
+	app.Post("/api/projects/tags", func(c fiber.Ctx) error {
+		return services.CreateTag(c, db)
+	})
+
+	app.Delete("/api/projects/tags", func(c fiber.Ctx) error {
+		return services.DeleteTag(c, db)
+	})
+
+	///////////////////////////////////////////////////////////////
+	// 						PROJECT RELEASES					 //
+	///////////////////////////////////////////////////////////////
+
+	app.Get("/api/projects/releases", func(c fiber.Ctx) error {
+		return services.ListReleases(c, db)
+	})
+
+	app.Post("/api/projects/releases", func(c fiber.Ctx) error {
+		return services.CreateRelease(c, db)
+	})
+
+	app.Put("/api/projects/releases/:releaseId", func(c fiber.Ctx) error {
+		return services.UpdateRelease(c, db)
+	})
+
+	app.Delete("/api/projects/releases/:releaseId", func(c fiber.Ctx) error {
+		return services.DeleteRelease(c, db)
+	})
+
+	app.Post("/api/projects/releases/:releaseId/artifacts", func(c fiber.Ctx) error {
+		return services.UploadReleaseArtifact(c, db)
+	})
+
+	app.Get("/api/projects/releases/:releaseId/artifacts/:artifactId", func(c fiber.Ctx) error {
+		return services.DownloadReleaseArtifact(c, db)
+	})
+
+	app.Delete("/api/projects/releases/:releaseId/artifacts/:artifactId", func(c fiber.Ctx) error {
+		return services.DeleteReleaseArtifact(c, db)
+	})
+
+	///////////////////////////////////////////////////////////////
+	// 						PROJECT TEAM						 //
+	///////////////////////////////////////////////////////////////
+
+	app.Get("/api/projects/team", func(c fiber.Ctx) error {
+		return services.ListProjectMembers(c, db)
+	})
+
+	app.Post("/api/projects/team/invite", func(c fiber.Ctx) error {
+		return services.InviteMember(c, db)
+	})
+
+	app.Put("/api/projects/team/role", func(c fiber.Ctx) error {
+		return services.ChangeMemberRole(c, db)
+	})
+
+	app.Post("/api/projects/team/deactivate", func(c fiber.Ctx) error {
+		return services.DeactivateMember(c, db)
+	})
+
+	app.Post("/api/projects/team/reactivate", func(c fiber.Ctx) error {
+		return services.ReactivateMember(c, db)
+	})
+
+	app.Post("/api/projects/team/transfer-ownership", func(c fiber.Ctx) error {
+		return services.TransferOwnership(c, db)
+	})
+
+	///////////////////////////////////////////////////////////////
+	// 						PROJECT ROLES						 //
+	///////////////////////////////////////////////////////////////
+
+	app.Get("/api/projects/roles", func(c fiber.Ctx) error {
+		return services.ListRoles(c, db)
+	})
+
+	app.Post("/api/projects/roles", func(c fiber.Ctx) error {
+		return services.CreateRole(c, db)
+	})
+
+	app.Post("/api/projects/roles/clone", func(c fiber.Ctx) error {
+		return services.CloneRole(c, db)
+	})
+
+	app.Put("/api/projects/roles/:roleId", func(c fiber.Ctx) error {
+		return services.UpdateRole(c, db)
+	})
+
+	///////////////////////////////////////////////////////////////
+	// 						AUTH								 //
+	///////////////////////////////////////////////////////////////
+
+	app.Post("/api/auth/token", func(c fiber.Ctx) error {
+		return services.IssueAPIToken(c, db)
+	})
+
+	///////////////////////////////////////////////////////////////
+	// 						ACCESS TOKENS						 //
+	///////////////////////////////////////////////////////////////
+
+	app.Get("/api/access-tokens", func(c fiber.Ctx) error {
+		return services.ListAccessTokens(c, db)
+	})
+
+	app.Post("/api/access-tokens", func(c fiber.Ctx) error {
+		return services.CreateAccessToken(c, db)
+	})
+
+	app.Delete("/api/access-tokens/:id", func(c fiber.Ctx) error {
+		return services.RevokeAccessToken(c, db)
+	})
+
+	///////////////////////////////////////////////////////////////
+	// 						SSH KEYS							 //
+	///////////////////////////////////////////////////////////////
+
+	app.Get("/api/ssh-keys", func(c fiber.Ctx) error {
+		return services.ListSSHKeys(c, db)
+	})
+
+	app.Post("/api/ssh-keys", func(c fiber.Ctx) error {
+		return services.AddSSHKey(c, db)
+	})
+
+	app.Delete("/api/ssh-keys/:id", func(c fiber.Ctx) error {
+		return services.DeleteSSHKey(c, db)
+	})
+
 	///////////////////////////////////////////////////////////////
 	// 						REPOSITORIES						 //
 	///////////////////////////////////////////////////////////////
@@ -70,4 +278,6 @@ func InitRoutes(app *fiber.App, db *sql.DB) {
 	app.Post("/api/repositories/:repo/git-receive-pack", func(c fiber.Ctx) error {
 		return services.HandleRPC(c)
 	})
+
+	middleware.LogUnprotectedRoutes(app, Permissions)
 }
@@ -0,0 +1,22 @@
+package routes
+
+import "project-manager-server/middleware"
+
+// xssConfig sanitizes request bodies except on routes carrying file contents,
+// commit and tag messages, release notes and other text that is stored
+// verbatim. The sanitizer would escape "<" and "&" and strip markup from
+// them. Responses escape the text instead: encoding/json writes <, > and &
+// as \u escapes, and clients must not render it as HTML.
+var xssConfig = middleware.XSSConfig{
+	SkipPaths: []string{
+		"/api/projects/save-file",
+		"/api/projects/new-file",
+		"/api/projects/new-folder",
+		"/api/projects/delete-file",
+		"/api/projects/commits",
+		"/api/projects/tags",
+		"/api/projects/releases",
+		"/api/projects/releases/:releaseId",
+	},
+	StrictPolicy: false,
+}
@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const verbatimContent = "if (a < b && c > d) {\n\treturn \"<script>alert('x')</script>\";\n}\n<div onclick=\"go()\">&amp; &lt;</div>\n"
+
+// newSanitizedApp registers handler behind the sanitizer InitRoutes uses
+func newSanitizedApp(method string, path string, handler fiber.Handler) *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.XSSMiddleware(xssConfig))
+	app.Add([]string{method}, path, handler)
+	return app
+}
+
+func sendJSON(t *testing.T, app *fiber.App, method string, path string, body any) {
+	t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, path, strings.NewReader(string(payload)))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: status %d: %s", method, path, resp.StatusCode, message)
+	}
+}
+
+func git(t *testing.T, gitDir string, args ...string) string {
+	t.Helper()
+
+	output, err := exec.Command("git", append([]string{"--git-dir=" + gitDir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+func TestCommitKeepsContentVerbatim(t *testing.T) {
+	gitDir := t.TempDir()
+	if output, err := exec.Command("git", "init", "--bare", "--quiet", gitDir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, output)
+	}
+
+	app := newSanitizedApp(fiber.MethodPost, "/api/projects/commits", func(c fiber.Ctx) error {
+		body := new(models.CommitRequest)
+		if err := c.Bind().Body(body); err != nil {
+			return err
+		}
+
+		author := models.CommitAuthor{Name: "Tester", Email: "tester@example.com"}
+		if _, err := lib.CommitFileChanges(gitDir, body.Branch, body.Changes, author, body.Message); err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{"error": false})
+	})
+
+	sendJSON(t, app, fiber.MethodPost, "/api/projects/commits", models.CommitRequest{
+		Message: "Handle a < b & <b>bold</b>",
+		Changes: []models.FileChange{
+			{Action: models.FileChangeCreate, Path: "src/index.html", Content: verbatimContent},
+		},
+	})
+
+	if got := git(t, gitDir, "cat-file", "blob", "HEAD:src/index.html"); got != verbatimContent {
+		t.Errorf("committed content = %q, want %q", got, verbatimContent)
+	}
+	if got := git(t, gitDir, "log", "-1", "--format=%B"); strings.TrimSpace(got) != "Handle a < b & <b>bold</b>" {
+		t.Errorf("commit message = %q", got)
+	}
+}
+
+func TestTextRoutesKeepBodiesVerbatim(t *testing.T) {
+	routes := []struct {
+		method string
+		route  string
+		path   string
+	}{
+		{fiber.MethodPost, "/api/projects/save-file", "/api/projects/save-file"},
+		{fiber.MethodPost, "/api/projects/new-file", "/api/projects/new-file"},
+		{fiber.MethodPost, "/api/projects/new-folder", "/api/projects/new-folder"},
+		{fiber.MethodDelete, "/api/projects/delete-file", "/api/projects/delete-file"},
+		{fiber.MethodPost, "/api/projects/tags", "/api/projects/tags"},
+		{fiber.MethodPost, "/api/projects/releases", "/api/projects/releases"},
+		{fiber.MethodPut, "/api/projects/releases/:releaseId", "/api/projects/releases/12"},
+	}
+
+	sent := map[string]string{"message": "Handle a < b && <b>c</b>", "content": verbatimContent}
+	for _, route := range routes {
+		var received map[string]string
+		app := newSanitizedApp(route.method, route.route, func(c fiber.Ctx) error {
+			return c.Bind().Body(&received)
+		})
+
+		sendJSON(t, app, route.method, route.path, sent)
+
+		if received["message"] != sent["message"] || received["content"] != sent["content"] {
+			t.Errorf("%s %s received %q", route.method, route.path, received)
+		}
+	}
+}
+
+func TestSanitizerStillAppliesToOtherRoutes(t *testing.T) {
+	var received map[string]string
+	app := newSanitizedApp(fiber.MethodPost, "/api/projects/team/invite", func(c fiber.Ctx) error {
+		return c.Bind().Body(&received)
+	})
+
+	sendJSON(t, app, fiber.MethodPost, "/api/projects/team/invite", map[string]string{"email": "<script>x</script>a@b.c"})
+
+	if received["email"] != "a@b.c" {
+		t.Errorf("email = %q, want the script removed", received["email"])
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func CreateAccessToken(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+	if user.SessionToken == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access tokens can only be created from browser sessions"})
+	}
+
+	body := new(models.CreateAccessTokenRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || len(body.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name is required and must be at most 100 characters"})
+	}
+
+	if len(body.Scopes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "At least one scope is required"})
+	}
+	for _, scope := range body.Scopes {
+		if !lib.ValidAccessTokenScope(scope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid scope " + scope + ", expected <resource>:<action>"})
+		}
+	}
+
+	if body.ExpiresAt.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "expiresAt is required"})
+	}
+	if !body.ExpiresAt.After(time.Now()) || body.ExpiresAt.After(time.Now().Add(lib.MaxAccessTokenLifetime)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "expiresAt must be in the future and within one year"})
+	}
+
+	token, accessToken, err := lib.CreateAccessToken(db, user.PrivateToken, *body)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create access token: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create access token"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false, "token": token, "accessToken": accessToken})
+}
+
+func ListAccessTokens(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+
+	accessTokens, err := lib.ListAccessTokens(db, user.PrivateToken)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list access tokens: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to list access tokens"})
+	}
+
+	return c.JSON(fiber.Map{"error": false, "accessTokens": accessTokens})
+}
+
+func RevokeAccessToken(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+	if user.AccessTokenID != 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access tokens cannot revoke access tokens"})
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid access token id"})
+	}
+
+	revoked, err := lib.RevokeAccessToken(db, user.PrivateToken, id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke access token: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke access token"})
+	}
+	if !revoked {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Access token not found"})
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"time"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const defaultAPITokenTTL = 15 * time.Minute
+
+// IssueAPIToken exchanges the current session for a short-lived signed token
+// that scripts and other services can send instead of the session token
+func IssueAPIToken(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+	if user.SessionToken == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Tokens can only be issued for browser sessions"})
+	}
+
+	tokens, err := lib.GetTokenService()
+	if err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Token signing is not configured"})
+	}
+
+	userPublicToken := lib.GetPublicTokenByPrivateToken(user.PrivateToken, db)
+	if userPublicToken == "" || userPublicToken == "error" {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to issue token"})
+	}
+
+	ttl := defaultAPITokenTTL
+	if value := os.Getenv("API_TOKEN_TTL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			ttl = parsed
+		}
+	}
+
+	token, expiresAt, err := tokens.Issue(userPublicToken, lib.APIAudience, ttl)
+	if err != nil {
+		log.Printf("[ERROR] Failed to issue token: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to issue token"})
+	}
+
+	return c.JSON(fiber.Map{"error": false, "token": token, "expiresAt": expiresAt})
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// GetBlame returns the last change of every line of a file, or of the lines
+// between start and end
+func GetBlame(c fiber.Ctx, db *sql.DB) error {
+	projectToken := middleware.GetProjectToken(c)
+	path := c.Query("path")
+	if path == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "path is required"})
+	}
+
+	ref := c.Query("ref")
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	lines := map[string]int{}
+	for _, name := range []string{"start", "end"} {
+		if value := c.Query(name); value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 1 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid " + name + " line"})
+			}
+			lines[name] = number
+		}
+	}
+
+	gitDir, err := lib.OpenRepository(projectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	blame, err := lib.GetBlame(gitDir, ref, path, lines["start"], lines["end"])
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	var emails []string
+	for _, blameRange := range blame.Ranges {
+		emails = append(emails, blameRange.Author.Email)
+	}
+
+	// Blame is still useful without the users, so a failed lookup only
+	// leaves them out
+	users, err := lib.MembersByEmail(db, projectToken, emails)
+	if err != nil {
+		log.Printf("[ERROR] Resolving blame authors failed: %v", err)
+	}
+	for i := range blame.Ranges {
+		if user, found := users[strings.ToLower(blame.Ranges[i].Author.Email)]; found {
+			blame.Ranges[i].User = &user
+		}
+	}
+
+	return c.JSON(fiber.Map{"error": false, "blame": blame})
+}
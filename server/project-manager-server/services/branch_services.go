@@ -0,0 +1,102 @@
+package services
+
+import (
+	"database/sql"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func ListBranches(c fiber.Ctx, db *sql.DB) error {
+	gitDir, err := lib.OpenRepository(middleware.GetProjectToken(c))
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	branches, defaultBranch, err := lib.ListBranches(gitDir)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "defaultBranch": defaultBranch, "branches": branches})
+}
+
+func CreateBranch(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.CreateBranchRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	gitDir, err := lib.OpenRepository(body.ProjectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	commit, err := lib.CreateBranch(gitDir, body.Name, body.From)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false, "branch": body.Name, "commit": commit})
+}
+
+func RenameBranch(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.RenameBranchRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	gitDir, err := lib.OpenRepository(body.ProjectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	if err := lib.RenameBranch(gitDir, body.Name, body.NewName); err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
+
+func DeleteBranch(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.DeleteBranchRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	gitDir, err := lib.OpenRepository(body.ProjectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	if err := lib.DeleteBranch(gitDir, body.Name); err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
+
+func SetDefaultBranch(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.DefaultBranchRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	gitDir, err := lib.OpenRepository(body.ProjectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	if err := lib.SetDefaultBranch(gitDir, body.Name); err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
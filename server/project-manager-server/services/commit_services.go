@@ -0,0 +1,228 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Upper bound of file changes in one commit
+const maxCommitChanges = 1000
+
+// Editor changes touch a single path, so they are simply reapplied when
+// another commit lands on the branch in the meantime
+const editorCommitAttempts = 3
+
+// commitEditorChange commits one change made in the editor on behalf of the
+// authenticated user
+func commitEditorChange(c fiber.Ctx, db *sql.DB, projectToken string, branch string, message string, change models.FileChange, defaultMessage string) error {
+	user := middleware.GetAuthenticatedUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Not authenticated"})
+	}
+
+	gitDir, err := lib.OpenRepository(projectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	author, err := lib.GetCommitAuthor(user.PrivateToken, db)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	if strings.TrimSpace(message) == "" {
+		message = defaultMessage
+	}
+
+	var result *models.CommitResult
+	for attempt := 1; ; attempt++ {
+		result, err = lib.CommitFileChanges(gitDir, branch, []models.FileChange{change}, author, message)
+		if !errors.Is(err, lib.ErrBranchMoved) || attempt == editorCommitAttempts {
+			break
+		}
+	}
+
+	if errors.Is(err, lib.ErrNoChanges) {
+		// Saving an unchanged file is not an error, there is just no commit
+		return c.JSON(fiber.Map{"error": false, "commit": nil})
+	}
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "commit": result})
+}
+
+// CommitChanges commits several file changes at once. The commit is only
+// made when the branch still points to the commit the client expects.
+func CommitChanges(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Not authenticated"})
+	}
+
+	body := new(models.CommitRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	if body.Branch == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "branch is required"})
+	}
+	if strings.TrimSpace(body.Message) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "message is required"})
+	}
+	if len(body.Changes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "changes are required"})
+	}
+	if len(body.Changes) > maxCommitChanges {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("at most %d changes can be committed at once", maxCommitChanges)})
+	}
+
+	// The route requires code:update, creating and deleting files need their
+	// own permissions
+	for _, action := range codeActions(body.Changes) {
+		if !hasPermission(db, user, body.ProjectToken, "code", action) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": fmt.Sprintf("You don't have permission to %s files", action)})
+		}
+	}
+
+	gitDir, err := lib.OpenRepository(body.ProjectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	author, err := lib.GetCommitAuthor(user.PrivateToken, db)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	result, err := lib.CommitFileChangesOnto(gitDir, body.Branch, body.ExpectedParent, body.Changes, author, body.Message)
+	if errors.Is(err, lib.ErrBranchMoved) {
+		head, headErr := lib.BranchHead(gitDir, body.Branch)
+		if headErr != nil {
+			return gitError(c, headErr)
+		}
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error(), "head": head})
+	}
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "commit": result})
+}
+
+// ListCommits returns a page of the history of a ref. since and until are
+// RFC 3339 dates.
+func ListCommits(c fiber.Ctx, db *sql.DB) error {
+	gitDir, err := lib.OpenRepository(middleware.GetProjectToken(c))
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	query := models.CommitLogQuery{
+		Ref:    c.Query("ref"),
+		Path:   c.Query("path"),
+		Author: c.Query("author"),
+		Cursor: c.Query("cursor"),
+	}
+
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit"})
+		}
+	}
+	for name, date := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := c.Query(name); value != "" {
+			if *date, err = time.Parse(time.RFC3339, value); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid %s date, expected RFC 3339", name)})
+			}
+		}
+	}
+
+	page, err := lib.GetCommitLog(gitDir, query)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "commits": page.Commits, "nextCursor": page.NextCursor})
+}
+
+func GetCommit(c fiber.Ctx, db *sql.DB) error {
+	gitDir, err := lib.OpenRepository(middleware.GetProjectToken(c))
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	commit, err := lib.GetCommitDetail(gitDir, c.Params("sha"))
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "commit": commit})
+}
+
+// hasPermission checks permissions beyond the one the route requires
+func hasPermission(db *sql.DB, user *models.AuthenticatedUser, projectToken string, resource string, action string) bool {
+	return lib.AccessTokenAllows(user, projectToken, resource, action) &&
+		lib.CheckUserPermissions(db, user.PrivateToken, projectToken, resource, action)
+}
+
+// codeActions returns the code permissions changes need besides update
+func codeActions(changes []models.FileChange) []string {
+	var create, remove bool
+	for _, change := range changes {
+		switch change.Action {
+		case models.FileChangeCreate:
+			create = true
+		case models.FileChangeDelete:
+			remove = true
+		case models.FileChangeRename:
+			create, remove = true, true
+		}
+	}
+
+	var actions []string
+	if create {
+		actions = append(actions, "create")
+	}
+	if remove {
+		actions = append(actions, "delete")
+	}
+	return actions
+}
+
+func gitError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, lib.ErrInvalidProjectToken), errors.Is(err, lib.ErrInvalidPath),
+		errors.Is(err, lib.ErrPathOutsideWorkspace), errors.Is(err, lib.ErrInvalidBranch),
+		errors.Is(err, lib.ErrInvalidChange), errors.Is(err, lib.ErrNoChanges),
+		errors.Is(err, lib.ErrEmptyCommitInfo), errors.Is(err, lib.ErrInvalidTag), errors.Is(err, lib.ErrInvalidCursor),
+		errors.Is(err, lib.ErrInvalidDiffOptions), errors.Is(err, lib.ErrInvalidLineRange),
+		errors.Is(err, lib.ErrInvalidSearch):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrRepositoryNotFound), errors.Is(err, lib.ErrBranchNotFound),
+		errors.Is(err, lib.ErrRefNotFound), errors.Is(err, lib.ErrTagNotFound), errors.Is(err, lib.ErrFileNotFound),
+		errors.Is(err, lib.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrFileExists), errors.Is(err, lib.ErrPathConflict), errors.Is(err, lib.ErrBranchMoved),
+		errors.Is(err, lib.ErrBranchExists), errors.Is(err, lib.ErrDefaultBranch), errors.Is(err, lib.ErrTagExists),
+		errors.Is(err, lib.ErrTagHasRelease):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[ERROR] Git operation failed: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Repository operation failed"})
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// CompareRefs returns the diff from one ref to another
+func CompareRefs(c fiber.Ctx, db *sql.DB) error {
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" || to == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from and to are required"})
+	}
+
+	options, err := diffOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	gitDir, err := lib.OpenRepository(middleware.GetProjectToken(c))
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	diff, err := lib.DiffRefs(gitDir, from, to, options)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "diff": diff})
+}
+
+// GetCommitDiff returns the changes of a commit against its first parent
+func GetCommitDiff(c fiber.Ctx, db *sql.DB) error {
+	options, err := diffOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	gitDir, err := lib.OpenRepository(middleware.GetProjectToken(c))
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	diff, err := lib.DiffCommit(gitDir, c.Params("sha"), options)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "diff": diff})
+}
+
+// diffOptions reads the ignoreWhitespace, context, maxFiles and maxBytes
+// query parameters, the limits themselves are checked by lib
+func diffOptions(c fiber.Ctx) (models.DiffOptions, error) {
+	options := models.DiffOptions{
+		IgnoreWhitespace: c.Query("ignoreWhitespace"),
+		ContextLines:     lib.DefaultDiffContextLines,
+	}
+
+	for name, value := range map[string]*int{"context": &options.ContextLines, "maxFiles": &options.MaxFiles, "maxBytes": &options.MaxBytes} {
+		if query := c.Query(name); query != "" {
+			number, err := strconv.Atoi(query)
+			if err != nil {
+				return options, fmt.Errorf("Invalid %s", name)
+			}
+			*value = number
+		}
+	}
+
+	return options, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const readinessTimeout = 2 * time.Second
+
+var draining atomic.Bool
+
+// SetDraining makes the readiness probe fail so no new traffic is routed to
+// the server while it shuts down.
+func SetDraining() {
+	draining.Store(true)
+}
+
+func Liveness(c fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+func Readiness(c fiber.Ctx, db *sql.DB) error {
+	if draining.Load() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "draining"})
+	}
+
+	checks := fiber.Map{}
+	ready := true
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		checks["database"] = failedCheck("database", err)
+		ready = false
+	} else {
+		checks["database"] = "ok"
+	}
+
+	dataDirs := map[string]string{
+		"projects":     os.Getenv("PROJECTS_FOLDER_PATH"),
+		"repositories": os.Getenv("REPOSITORIES_FOLDER_PATH"),
+	}
+	// Release artifacts are optional, uploads fail until the folder is set
+	if releasesFolder := os.Getenv("RELEASES_FOLDER_PATH"); releasesFolder != "" {
+		dataDirs["releases"] = releasesFolder
+	}
+	for name, dir := range dataDirs {
+		if err := checkWritable(dir); err != nil {
+			checks[name] = failedCheck(name, err)
+			ready = false
+		} else {
+			checks[name] = "ok"
+		}
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		checks["git"] = failedCheck("git", err)
+		ready = false
+	} else {
+		checks["git"] = "ok"
+	}
+
+	if !ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "not ready", "checks": checks})
+	}
+	return c.JSON(fiber.Map{"status": "ready", "checks": checks})
+}
+
+func checkWritable(dir string) error {
+	if dir == "" {
+		return os.ErrNotExist
+	}
+
+	file, err := os.CreateTemp(dir, ".ready-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// failedCheck logs why a readiness check failed. Probes are unauthenticated,
+// errors name paths and connection details so they stay in the log.
+func failedCheck(name string, err error) string {
+	log.Printf("[ERROR] Readiness check %s failed: %v", name, err)
+	return "fail"
+}
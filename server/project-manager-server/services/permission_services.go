@@ -0,0 +1,71 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func GetPermissionCacheStats(c fiber.Ctx) error {
+	return c.JSON(lib.GetPermissionCacheStats())
+}
+
+// GetEffectivePermissions lists what the current user may do on a project
+func GetEffectivePermissions(c fiber.Ctx, db *sql.DB) error {
+	projectToken := middleware.GetProjectToken(c)
+	if projectToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "projectToken is required"})
+	}
+
+	user := middleware.GetAuthenticatedUser(c)
+
+	effective, err := lib.GetEffectivePermissions(db, user.PrivateToken, projectToken)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get effective permissions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get permissions"})
+	}
+
+	// Requests made with an access token only get what its scopes allow
+	if user.AccessTokenID != 0 {
+		grants := []models.PermissionGrant{}
+		for _, grant := range effective.Grants {
+			if lib.AccessTokenAllows(user, projectToken, grant.Resource, grant.Action) {
+				grants = append(grants, grant)
+				continue
+			}
+			grant.Source = models.GrantSourceScope
+			effective.Withheld = append(effective.Withheld, grant)
+		}
+		effective.Grants = grants
+	}
+
+	return c.JSON(effective)
+}
+
+// GetMemberEffectivePermissions lists what any user may do on a project, for
+// members managing roles who debug someone's access
+func GetMemberEffectivePermissions(c fiber.Ctx, db *sql.DB) error {
+	projectToken := middleware.GetProjectToken(c)
+	userPublicToken := c.Query("userPublicToken")
+	if projectToken == "" || userPublicToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "projectToken and userPublicToken are required"})
+	}
+
+	userPrivateToken := lib.GetPrivateTokenByPublicToken(userPublicToken, db)
+	if userPrivateToken == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	effective, err := lib.GetEffectivePermissions(db, userPrivateToken, projectToken)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get effective permissions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get permissions"})
+	}
+
+	return c.JSON(effective)
+}
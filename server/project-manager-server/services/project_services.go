@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	"database/sql"
 	"project-manager-server/lib"
+	"project-manager-server/middleware"
 	"project-manager-server/models"
 
 	"github.com/gofiber/fiber/v3"
@@ -39,7 +41,10 @@ func AddProjectEntry(c fiber.Ctx, db *sql.DB) error {
 
 	projectType := strings.ToLower(body.Type)
 
-	projectToken := lib.CreateToken()
+	projectToken, err := lib.CreateProjectToken()
+	if err != nil {
+		return c.Status(500).SendString("Failed to create project")
+	}
 	RepoPath := os.Getenv("PROJECTS_FOLDER_PATH")
 
 	rows, err := db.Query("INSERT INTO projects (projectname, projecttoken, repo_url, checked_out_by, status, type) VALUES ($1, $2, $3, $4, $5, $6);", body.Project_name, projectToken, repoURL, userPrivateToken, "checking-out", projectType)
@@ -75,7 +80,11 @@ func AddProjectEntry(c fiber.Ctx, db *sql.DB) error {
 		return err
 	}
 
-	os.WriteFile(fmt.Sprintf("%s/%s/project-config.json", RepoPath, projectToken), []byte(jsonConfig), 0644)
+	configPath, err := lib.ResolveWorkspacePath(RepoPath, projectToken, "project-config.json")
+	if err != nil {
+		return workspacePathError(c, err)
+	}
+	os.WriteFile(configPath, []byte(jsonConfig), 0644)
 
 	// Update project status in database
 	_, err = db.Exec("UPDATE projects SET status = 'checked-out' WHERE project_token = $1", projectToken)
@@ -96,10 +105,18 @@ func CreateProject(c fiber.Ctx, db *sql.DB) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
 	}
 
-	ProjectToken := lib.CreateToken()
+	ProjectToken, err := lib.CreateProjectToken()
+	if err != nil {
+		return c.Status(500).SendString("Failed to create project")
+	}
 
-	os.MkdirAll(fmt.Sprintf("%s/%s", os.Getenv("PROJECTS_FOLDER_PATH"), ProjectToken), 0755)
-	os.WriteFile(fmt.Sprintf("%s/%s/project-config.json", os.Getenv("PROJECTS_FOLDER_PATH"), ProjectToken), []byte("{}"), 0644)
+	workspaceRoot, err := lib.WorkspaceRoot(os.Getenv("PROJECTS_FOLDER_PATH"), ProjectToken)
+	if err != nil {
+		return c.Status(500).SendString("Failed to create project")
+	}
+
+	os.MkdirAll(workspaceRoot, 0755)
+	os.WriteFile(filepath.Join(workspaceRoot, "project-config.json"), []byte("{}"), 0644)
 
 	userPrivateToken := lib.GetPrivateTokenBySessionToken(body.SessionToken, db)
 
@@ -159,7 +176,7 @@ VALUES ($1, (SELECT userPrivateToken FROM users WHERE UserEmail = $2), (SELECT i
 }
 
 func GetRepositoryTree(c fiber.Ctx, db *sql.DB) error {
-	projectToken := c.Query("projectToken")
+	projectToken := middleware.GetProjectToken(c)
 	branch := c.Query("branch")
 
 	fmt.Println("projectToken:", projectToken)
@@ -169,19 +186,22 @@ func GetRepositoryTree(c fiber.Ctx, db *sql.DB) error {
 		branch = "HEAD"
 	}
 
-	basePath, err := filepath.Abs(os.Getenv("REPOSITORIES_FOLDER_PATH"))
+	gitDir, err := lib.RepositoryPath(os.Getenv("REPOSITORIES_FOLDER_PATH"), projectToken)
 	if err != nil {
-		log.Fatalf("Failed to resolve base path: %v", err)
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
 
-	gitDir := filepath.Join(basePath, projectToken+".git")
-
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
 		log.Printf("Repository not found at: %s", gitDir)
 		return c.Status(fiber.StatusNotFound).SendString("Repository not found")
 	}
 
-	tree, err := lib.GetDirectoryStructureFromGit(gitDir, branch, "")
+	commit, err := lib.ResolveCommit(gitDir, branch)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Branch not found")
+	}
+
+	tree, err := lib.GetDirectoryStructureFromGit(gitDir, commit, "")
 	if err != nil {
 		log.Printf("Failed to get tree: %v", err)
 		return c.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Failed to get tree: %v", err))
@@ -192,7 +212,7 @@ func GetRepositoryTree(c fiber.Ctx, db *sql.DB) error {
 
 func GetRepositoryFile(c fiber.Ctx, db *sql.DB) error {
 	filePath := c.Query("path")
-	projectToken := c.Query("projectToken")
+	projectToken := middleware.GetProjectToken(c)
 	branch := c.Query("branch")
 
 	log.Println("filePath:", filePath)
@@ -203,23 +223,26 @@ func GetRepositoryFile(c fiber.Ctx, db *sql.DB) error {
 		branch = "HEAD"
 	}
 
-	if strings.Contains(filePath, "..") || strings.HasPrefix(filePath, "/") {
+	filePath, err := lib.CleanRelativePath(filePath)
+	if err != nil {
 		return c.Status(fiber.StatusForbidden).SendString("Access to this file is not allowed")
 	}
 
-
-	basePath, err := filepath.Abs(os.Getenv("REPOSITORIES_FOLDER_PATH"))
+	gitDir, err := lib.RepositoryPath(os.Getenv("REPOSITORIES_FOLDER_PATH"), projectToken)
 	if err != nil {
-		log.Fatalf("Failed to resolve base path: %v", err)
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
 
-	gitDir := filepath.Join(basePath, projectToken+".git")
-
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
 		return c.Status(fiber.StatusNotFound).SendString("Repository not found")
 	}
 
-	content, err := getFileFromGitCatFile(gitDir, branch, filePath)
+	commit, err := lib.ResolveCommit(gitDir, branch)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Branch not found")
+	}
+
+	content, err := getFileFromGitCatFile(gitDir, commit, filePath)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Failed to read file: %v", err))
 	}
@@ -254,18 +277,14 @@ func CreateNewDirectory(c fiber.Ctx, db *sql.DB) error {
 	if err := c.Bind().Body(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
 	}
-	RepoPath := os.Getenv("PROJECTS_FOLDER_PATH")
-
-	fullFilePath := fmt.Sprintf("%s/%s/%s", RepoPath, body.ProjectToken, body.Path)
-
-	err := os.MkdirAll(fullFilePath, 0755)
-	if err != nil {
-		log.Println(err)
+	body.ProjectToken = middleware.GetProjectToken(c)
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create directory"})
+	change := models.FileChange{
+		Action: models.FileChangeCreate,
+		Path:   strings.TrimRight(body.Path, "/") + "/" + lib.FolderPlaceholder,
 	}
 
-	return c.JSON(fiber.Map{"error": false})
+	return commitEditorChange(c, db, body.ProjectToken, body.Branch, body.Message, change, "Create folder "+body.Path)
 }
 
 func CreateNewFile(c fiber.Ctx, db *sql.DB) error {
@@ -274,17 +293,11 @@ func CreateNewFile(c fiber.Ctx, db *sql.DB) error {
 	if err := c.Bind().Body(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
 	}
-	RepoPath := os.Getenv("PROJECTS_FOLDER_PATH")
-	fullFilePath := fmt.Sprintf("%s/%s/%s", RepoPath, body.ProjectToken, body.Path)
+	body.ProjectToken = middleware.GetProjectToken(c)
 
-	err := os.WriteFile(fullFilePath, []byte(""), 0644)
-	if err != nil {
-		log.Println(err)
+	change := models.FileChange{Action: models.FileChangeCreate, Path: body.Path}
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create directory"})
-	}
-
-	return c.JSON(fiber.Map{"error": false})
+	return commitEditorChange(c, db, body.ProjectToken, body.Branch, body.Message, change, "Create "+body.Path)
 }
 
 func SaveRepositoryFile(c fiber.Ctx, db *sql.DB) error {
@@ -294,16 +307,11 @@ func SaveRepositoryFile(c fiber.Ctx, db *sql.DB) error {
 	if err := c.Bind().Body(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
 	}
+	body.ProjectToken = middleware.GetProjectToken(c)
 
-	RepoPath := os.Getenv("PROJECTS_FOLDER_PATH")
-
-	fullFilePath := fmt.Sprintf("%s/%s/%s", RepoPath, body.ProjectToken, body.Path)
-	err := lib.SaveFile(fullFilePath, body.Content)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete file"})
-	}
+	change := models.FileChange{Action: models.FileChangeUpdate, Path: body.Path, Content: body.Content}
 
-	return c.JSON(fiber.Map{"error": false})
+	return commitEditorChange(c, db, body.ProjectToken, body.Branch, body.Message, change, "Update "+body.Path)
 }
 
 func DeleteFile(c fiber.Ctx, db *sql.DB) error {
@@ -312,11 +320,21 @@ func DeleteFile(c fiber.Ctx, db *sql.DB) error {
 	if err := c.Bind().Body(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
 	}
-	RepoPath := os.Getenv("PROJECTS_FOLDER_PATH")
-	fullFilePath := fmt.Sprintf("%s/%s/%s", RepoPath, body.ProjectToken, body.Path)
-	err := os.Remove(fullFilePath)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete file"})
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	change := models.FileChange{Action: models.FileChangeDelete, Path: body.Path}
+
+	return commitEditorChange(c, db, body.ProjectToken, body.Branch, body.Message, change, "Delete "+body.Path)
+}
+
+func workspacePathError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, lib.ErrInvalidProjectToken), errors.Is(err, lib.ErrInvalidPath):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrPathOutsideWorkspace):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.JSON(fiber.Map{"error": false})
+
+	log.Printf("[ERROR] Workspace path resolution failed: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resolve the path"})
 }
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ListReleases shows drafts only to users who can edit releases
+func ListReleases(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+	projectToken := middleware.GetProjectToken(c)
+
+	includeDrafts := hasPermission(db, user, projectToken, "code", "update")
+
+	releases, err := lib.ListReleases(db, projectToken, includeDrafts)
+	if err != nil {
+		return releaseError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "releases": releases})
+}
+
+func CreateRelease(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+
+	body := new(models.CreateReleaseRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	gitDir, err := lib.OpenRepository(body.ProjectToken)
+	if err != nil {
+		return releaseError(c, err)
+	}
+
+	if err := lib.ValidateTagName(body.TagName); err != nil {
+		return releaseError(c, err)
+	}
+	exists, err := lib.TagExists(gitDir, body.TagName)
+	if err != nil {
+		return releaseError(c, err)
+	}
+	if !exists {
+		return releaseError(c, lib.ErrTagNotFound)
+	}
+
+	release, err := lib.CreateRelease(db, body.ProjectToken, user.PrivateToken, *body)
+	if err != nil {
+		return releaseError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false, "release": release})
+}
+
+func UpdateRelease(c fiber.Ctx, db *sql.DB) error {
+	releaseID, err := strconv.Atoi(c.Params("releaseId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid release id"})
+	}
+
+	body := new(models.UpdateReleaseRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	release, err := lib.UpdateRelease(db, body.ProjectToken, releaseID, *body)
+	if err != nil {
+		return releaseError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "release": release})
+}
+
+func DeleteRelease(c fiber.Ctx, db *sql.DB) error {
+	releaseID, err := strconv.Atoi(c.Params("releaseId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid release id"})
+	}
+
+	body := new(models.DeleteReleaseRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	if err := lib.DeleteRelease(db, body.ProjectToken, releaseID); err != nil {
+		return releaseError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
+
+// UploadReleaseArtifact takes the artifact as the "file" field of a
+// multipart form, the project comes from the query string
+func UploadReleaseArtifact(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+
+	releaseID, err := strconv.Atoi(c.Params("releaseId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid release id"})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file is required"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return releaseError(c, err)
+	}
+	defer file.Close()
+
+	artifact, err := lib.AddReleaseArtifact(db, middleware.GetProjectToken(c), releaseID, user.PrivateToken,
+		fileHeader.Filename, fileHeader.Header.Get(fiber.HeaderContentType), file)
+	if err != nil {
+		return releaseError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false, "artifact": artifact})
+}
+
+// DownloadReleaseArtifact streams an artifact, drafts only to users who can
+// edit releases
+func DownloadReleaseArtifact(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+	projectToken := middleware.GetProjectToken(c)
+
+	releaseID, err := strconv.Atoi(c.Params("releaseId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid release id"})
+	}
+	artifactID, err := strconv.Atoi(c.Params("artifactId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid artifact id"})
+	}
+
+	includeDrafts := hasPermission(db, user, projectToken, "code", "update")
+
+	artifact, file, err := lib.OpenReleaseArtifact(db, projectToken, releaseID, artifactID, includeDrafts)
+	if err != nil {
+		return releaseError(c, err)
+	}
+
+	// The content type comes from the uploader, never let browsers render it
+	c.Attachment(artifact.FileName)
+	c.Set(fiber.HeaderContentType, artifact.ContentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.SendStream(file, int(artifact.SizeBytes))
+}
+
+func DeleteReleaseArtifact(c fiber.Ctx, db *sql.DB) error {
+	releaseID, err := strconv.Atoi(c.Params("releaseId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid release id"})
+	}
+	artifactID, err := strconv.Atoi(c.Params("artifactId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid artifact id"})
+	}
+
+	body := new(models.DeleteReleaseRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	if err := lib.DeleteReleaseArtifact(db, body.ProjectToken, releaseID, artifactID); err != nil {
+		return releaseError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
+
+func releaseError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, lib.ErrInvalidRelease):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrReleaseNotFound), errors.Is(err, lib.ErrArtifactNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrReleaseExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrReleasesFolderUnset):
+		log.Printf("[ERROR] Release artifacts are unavailable: %v", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Release artifacts are not configured on this server"})
+	}
+
+	return gitError(c, err)
+}
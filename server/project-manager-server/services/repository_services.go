@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"project-manager-server/lib"
+	"project-manager-server/middleware"
 	"project-manager-server/models"
 	"strings"
 
@@ -23,19 +24,16 @@ func GenerateRepository(c fiber.Ctx, db *sql.DB) error {
 	if err := c.Bind().Body(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
 	}
+	body.ProjectToken = middleware.GetProjectToken(c)
 
-	projectPath := fmt.Sprintf("%s/%s", os.Getenv("PROJECTS_FOLDER_PATH"), body.ProjectToken)
-	repoPath := fmt.Sprintf("%s/%s.git", os.Getenv("REPOSITORIES_FOLDER_PATH"), body.ProjectToken) // Ensure .git suffix
-
-	// Convert to absolute paths
-	absProjectPath, err := filepath.Abs(projectPath)
+	absProjectPath, err := lib.WorkspaceRoot(os.Getenv("PROJECTS_FOLDER_PATH"), body.ProjectToken)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get absolute project path"})
+		return workspacePathError(c, err)
 	}
 
-	absRepoPath, err := filepath.Abs(repoPath)
+	absRepoPath, err := lib.RepositoryPath(os.Getenv("REPOSITORIES_FOLDER_PATH"), body.ProjectToken)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get absolute repo path"})
+		return workspacePathError(c, err)
 	}
 
 	if _, err := os.Stat(absRepoPath); err == nil {
@@ -156,14 +154,14 @@ func GenerateRepository(c fiber.Ctx, db *sql.DB) error {
 	}
 
 	//update project repoUrl
-	rows, err := db.Query("UPDATE projects_codebase SET RepositoryUrl = $1 WHERE ProjectToken = $2;", fmt.Sprintf("http://%s:5200/api/repositories/%s.git", os.Getenv("SERVER_HOST"), body.ProjectToken), body.ProjectToken)
+	rows, err := db.Query("UPDATE projects_codebase SET RepositoryUrl = $1 WHERE ProjectToken = $2;", lib.GetRepositoryUrl(body.ProjectToken), body.ProjectToken)
 	if err != nil {
 		log.Println("Error updating project repoUrl in database:", err)
 		return c.Status(500).SendString("Failed to update project repoUrl in database")
 	}
 	defer rows.Close()
 
-	return c.JSON(fiber.Map{"error": false, "repoUrl": fmt.Sprintf("http://%s:5200/api/repositories/%s.git", os.Getenv("SERVER_HOST"), body.ProjectToken)})
+	return c.JSON(fiber.Map{"error": false, "repoUrl": lib.GetRepositoryUrl(body.ProjectToken)})
 }
 
 func HandleInfoRefs(c fiber.Ctx) error {
@@ -174,14 +172,9 @@ func HandleInfoRefs(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid service"})
 	}
 
-	repoPath := filepath.Join(os.Getenv("REPOSITORIES_FOLDER_PATH"), repoName)
-	absPath, err := filepath.Abs(repoPath)
+	absPath, err := lib.RepositoryPath(os.Getenv("REPOSITORIES_FOLDER_PATH"), strings.TrimSuffix(repoName, ".git"))
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	if !strings.HasSuffix(absPath, ".git") {
-		absPath += ".git"
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid repository"})
 	}
 
 	log.Printf("Accessing repository at: %s", absPath)
@@ -235,14 +228,9 @@ func HandleRPC(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid service")
 	}
 
-	repoPath := filepath.Join(os.Getenv("REPOSITORIES_FOLDER_PATH"), repoName)
-	absPath, err := filepath.Abs(repoPath)
+	absPath, err := lib.RepositoryPath(os.Getenv("REPOSITORIES_FOLDER_PATH"), strings.TrimSuffix(repoName, ".git"))
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	if !strings.HasSuffix(absPath, ".git") {
-		absPath += ".git"
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid repository"})
 	}
 
 	log.Printf("RPC service %s on repository: %s", rpcService, absPath)
@@ -310,15 +298,17 @@ func CreateRepo(c fiber.Ctx, db *sql.DB) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
 
 	}
-
-	// Example permission check (uncomment when implemented)
-	permission := lib.CheckPermissions(db, body.UserSessionToken, body.ProjectToken, "code", "create")
-	if !permission {
-		return c.Status(fiber.StatusForbidden).SendString("You don't have permission to create a repository")
-	}
+	// RBACMiddleware authorized code:create on this project for any kind of
+	// credentials, the body's session token is not needed
+	body.ProjectToken = middleware.GetProjectToken(c)
 
 	projectToken := body.ProjectToken
 
+	repoPath, err := lib.RepositoryPath(os.Getenv("REPOSITORIES_FOLDER_PATH"), projectToken)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
 	if body.Mode == "create" {
 		if body.RepositoryName == nil {
 			return c.Status(fiber.StatusBadRequest).SendString("repositoryName is required in create mode")
@@ -326,8 +316,6 @@ func CreateRepo(c fiber.Ctx, db *sql.DB) error {
 
 		repoName := *body.RepositoryName
 
-		repoPath := filepath.Join(os.Getenv("REPOSITORIES_FOLDER_PATH"), body.ProjectToken+".git")
-
 		if _, err := os.Stat(repoPath); err == nil {
 			log.Printf("Repository already exists: %s", repoPath)
 			return c.Status(fiber.StatusConflict).SendString("Repository already exists")
@@ -367,7 +355,7 @@ func CreateRepo(c fiber.Ctx, db *sql.DB) error {
 			body.InitializeWithReadme,
 			body.GitignoreTemplate,
 			body.License,
-			fmt.Sprintf("http://%s:5200/api/repositories/%s.git", os.Getenv("SERVER_HOST"), body.ProjectToken),
+			lib.GetRepositoryUrl(body.ProjectToken),
 			body.ProjectType,
 			body.Branch,
 			body.AuthMethod,
@@ -438,8 +426,6 @@ func CreateRepo(c fiber.Ctx, db *sql.DB) error {
 		repoName := strings.Split(sourceUrl, "/")[len(strings.Split(sourceUrl, "/"))-1]
 		repoName = strings.Split(repoName, ".")[0]
 
-		repoPath := filepath.Join(os.Getenv("REPOSITORIES_FOLDER_PATH"), projectToken+".git")
-
 		if _, err := os.Stat(repoPath); err == nil {
 			log.Printf("Repository already exists: %s", repoPath)
 			return c.Status(fiber.StatusConflict).SendString("Repository already exists on this server")
@@ -557,7 +543,7 @@ func CreateRepo(c fiber.Ctx, db *sql.DB) error {
 
 		log.Printf("Created and committed config file to repository")
 
-		ourRepoUrl := fmt.Sprintf("http://%s:5200/api/repositories/%s.git", os.Getenv("SERVER_HOST"), projectToken)
+		ourRepoUrl := lib.GetRepositoryUrl(projectToken)
 
 		_, err = db.Exec(`
     INSERT INTO projects_codebase (
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func ListRoles(c fiber.Ctx, db *sql.DB) error {
+	roles, err := lib.ListRoles(db, middleware.GetProjectToken(c))
+	if err != nil {
+		log.Printf("[ERROR] Failed to list roles: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to list roles"})
+	}
+
+	return c.JSON(fiber.Map{"error": false, "roles": roles})
+}
+
+func CreateRole(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.RoleRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	user := middleware.GetAuthenticatedUser(c)
+	roleID, err := lib.CreateRole(db, *body, user.PrivateToken)
+	if err != nil {
+		return roleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false, "roleId": roleID})
+}
+
+func CloneRole(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.CloneRoleRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	user := middleware.GetAuthenticatedUser(c)
+	roleID, err := lib.CloneRole(db, *body, user.PrivateToken)
+	if err != nil {
+		return roleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false, "roleId": roleID})
+}
+
+func UpdateRole(c fiber.Ctx, db *sql.DB) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid role id"})
+	}
+
+	body := new(models.RoleRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	user := middleware.GetAuthenticatedUser(c)
+	if err := lib.UpdateRole(db, roleID, *body, user.PrivateToken); err != nil {
+		return roleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
+
+func roleError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, lib.ErrRoleNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrRoleExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrInvalidRole), errors.Is(err, lib.ErrPermissionNotFound),
+		errors.Is(err, lib.ErrRoleInheritanceCycle), errors.Is(err, lib.ErrOwnerRole):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrSystemRole), errors.Is(err, lib.ErrRoleAboveOwn):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[ERROR] Role change failed: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save the role"})
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// SearchCode searches the file contents at a ref. include and exclude are
+// comma separated glob patterns.
+func SearchCode(c fiber.Ctx, db *sql.DB) error {
+	gitDir, err := lib.OpenRepository(middleware.GetProjectToken(c))
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	query := models.SearchQuery{
+		Ref:     c.Query("ref"),
+		Query:   c.Query("q"),
+		Mode:    c.Query("mode"),
+		Include: splitPatterns(c.Query("include")),
+		Exclude: splitPatterns(c.Query("exclude")),
+		Cursor:  c.Query("cursor"),
+	}
+
+	if value := c.Query("ignoreCase"); value != "" {
+		if query.IgnoreCase, err = strconv.ParseBool(value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ignoreCase"})
+		}
+	}
+	for name, number := range map[string]*int{"context": &query.ContextLines, "limit": &query.Limit} {
+		if value := c.Query(name); value != "" {
+			if *number, err = strconv.Atoi(value); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid " + name})
+			}
+		}
+	}
+
+	page, err := lib.SearchCode(gitDir, query)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":      false,
+		"commit":     page.Commit,
+		"matches":    page.Matches,
+		"nextCursor": page.NextCursor,
+		"timedOut":   page.TimedOut,
+	})
+}
+
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func AddSSHKey(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+	if user.SessionToken == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "SSH keys can only be registered from browser sessions"})
+	}
+
+	body := new(models.AddSSHKeyRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || len(body.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name is required and must be at most 100 characters"})
+	}
+
+	sshKey, err := lib.AddSSHKey(db, user.PrivateToken, body.Name, body.PublicKey)
+	if errors.Is(err, lib.ErrSSHKeyExists) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This SSH key is already registered"})
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to add SSH key: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid public key, expected the content of a .pub file"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false, "sshKey": sshKey})
+}
+
+func ListSSHKeys(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+
+	sshKeys, err := lib.ListSSHKeys(db, user.PrivateToken)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list SSH keys: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to list SSH keys"})
+	}
+
+	return c.JSON(fiber.Map{"error": false, "sshKeys": sshKeys})
+}
+
+func DeleteSSHKey(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+	if user.AccessTokenID != 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access tokens cannot revoke SSH keys"})
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid SSH key id"})
+	}
+
+	deleted, err := lib.DeleteSSHKey(db, user.PrivateToken, id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete SSH key: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete SSH key"})
+	}
+	if !deleted {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "SSH key not found"})
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
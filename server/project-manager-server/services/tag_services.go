@@ -0,0 +1,79 @@
+package services
+
+import (
+	"database/sql"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func ListTags(c fiber.Ctx, db *sql.DB) error {
+	gitDir, err := lib.OpenRepository(middleware.GetProjectToken(c))
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	tags, err := lib.ListTags(gitDir)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false, "tags": tags})
+}
+
+func CreateTag(c fiber.Ctx, db *sql.DB) error {
+	user := middleware.GetAuthenticatedUser(c)
+
+	body := new(models.CreateTagRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	gitDir, err := lib.OpenRepository(body.ProjectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	tagger, err := lib.GetCommitAuthor(user.PrivateToken, db)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	tag, err := lib.CreateTag(gitDir, body.Name, body.From, body.Message, tagger)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false, "tag": tag})
+}
+
+func DeleteTag(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.DeleteTagRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	gitDir, err := lib.OpenRepository(body.ProjectToken)
+	if err != nil {
+		return gitError(c, err)
+	}
+
+	inUse, err := lib.ReleaseTagInUse(db, body.ProjectToken, body.Name)
+	if err != nil {
+		return gitError(c, err)
+	}
+	if inUse {
+		return gitError(c, lib.ErrTagHasRelease)
+	}
+
+	if err := lib.DeleteTag(gitDir, body.Name); err != nil {
+		return gitError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"project-manager-server/lib"
+	"project-manager-server/middleware"
+	"project-manager-server/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func ListProjectMembers(c fiber.Ctx, db *sql.DB) error {
+	members, err := lib.ListProjectMembers(db, middleware.GetProjectToken(c))
+	if err != nil {
+		log.Printf("[ERROR] Failed to list project members: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to list project members"})
+	}
+
+	return c.JSON(fiber.Map{"error": false, "members": members})
+}
+
+func InviteMember(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.InviteMemberRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+	if body.Email == "" || body.Role == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "email and role are required"})
+	}
+
+	user := middleware.GetAuthenticatedUser(c)
+	err := lib.InviteMember(db, body.ProjectToken, body.Email, body.Role, user.PrivateToken, body.Reason)
+	if err != nil {
+		return teamError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"error": false})
+}
+
+func ChangeMemberRole(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.ChangeMemberRoleRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+	if body.Role == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role is required"})
+	}
+
+	memberPrivateToken := lib.GetPrivateTokenByPublicToken(body.UserPublicToken, db)
+	if memberPrivateToken == "" {
+		return teamError(c, lib.ErrUserNotFound)
+	}
+
+	user := middleware.GetAuthenticatedUser(c)
+	err := lib.ChangeMemberRole(db, body.ProjectToken, memberPrivateToken, body.Role, user.PrivateToken, body.Reason)
+	if err != nil {
+		return teamError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
+
+func DeactivateMember(c fiber.Ctx, db *sql.DB) error {
+	return setMemberActive(c, db, false)
+}
+
+func ReactivateMember(c fiber.Ctx, db *sql.DB) error {
+	return setMemberActive(c, db, true)
+}
+
+func setMemberActive(c fiber.Ctx, db *sql.DB, active bool) error {
+	body := new(models.MemberStatusRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	memberPrivateToken := lib.GetPrivateTokenByPublicToken(body.UserPublicToken, db)
+	if memberPrivateToken == "" {
+		return teamError(c, lib.ErrUserNotFound)
+	}
+
+	user := middleware.GetAuthenticatedUser(c)
+	err := lib.SetMemberActive(db, body.ProjectToken, memberPrivateToken, active, user.PrivateToken, body.Reason)
+	if err != nil {
+		return teamError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
+
+func TransferOwnership(c fiber.Ctx, db *sql.DB) error {
+	body := new(models.TransferOwnershipRequest)
+	if err := c.Bind().Body(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse body"})
+	}
+	body.ProjectToken = middleware.GetProjectToken(c)
+
+	newOwnerPrivateToken := lib.GetPrivateTokenByPublicToken(body.UserPublicToken, db)
+	if newOwnerPrivateToken == "" {
+		return teamError(c, lib.ErrUserNotFound)
+	}
+
+	user := middleware.GetAuthenticatedUser(c)
+	err := lib.TransferOwnership(db, body.ProjectToken, newOwnerPrivateToken, body.PreviousOwnerRole, user.PrivateToken, body.Reason)
+	if err != nil {
+		return teamError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"error": false})
+}
+
+func teamError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, lib.ErrUserNotFound), errors.Is(err, lib.ErrRoleNotFound), errors.Is(err, lib.ErrMemberNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrMemberExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrOwnerRole):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, lib.ErrRoleAboveOwn), errors.Is(err, lib.ErrMemberAboveOwn), errors.Is(err, lib.ErrNotOwner):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[ERROR] Team membership change failed: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update the project team"})
+}